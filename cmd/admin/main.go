@@ -0,0 +1,892 @@
+// Command admin is the operator tool for maintenance tasks that should not be
+// exposed through the API. It uses the caller's AWS credentials.
+//
+// Run it without arguments to list the available commands.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"hpmaster/internal/cache"
+	"hpmaster/internal/dedupe"
+	"hpmaster/internal/projection"
+	"hpmaster/internal/report"
+	"hpmaster/internal/store"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(s *store.Store, args []string) error
+}
+
+var commands = []command{
+	{"users", "users [-all]", runUsers},
+	{"find", "find -email <email> | -user <userId>", runFind},
+	{"set-role", "set-role -user <userId> -role <role>", runSetRole},
+	{"disable", "disable -user <userId>", runDisable},
+	{"enable", "enable -user <userId>", runEnable},
+	{"grant-freezes", "grant-freezes -user <userId> [-count <n>]", runGrantFreezes},
+	{"export", "export -user <userId> [-residency <residency>] [-reports-bucket <bucket>] [-out <file>]", runExport},
+	{"invalidate-cache", "invalidate-cache", runInvalidateCache},
+	{"merge", "merge -from <userId> -to <userId>", runMerge},
+	{"put-pack", "put-pack -file <pack.json>", runPutPack},
+	{"put-event", "put-event -file <event.json>", runPutEvent},
+	{"put-group", "put-group -file <group.json>", runPutGroup},
+	{"add-group-member", "add-group-member -group <groupId> -user <userId>", runAddGroupMember},
+	{"remove-group-member", "remove-group-member -group <groupId> -user <userId>", runRemoveGroupMember},
+	{"import-words", "import-words -file <words.json> [-dry-run] [-force]", runImportWords},
+	{"suggestions", "suggestions [-status <status>]", runSuggestions},
+	{"approve-suggestion", "approve-suggestion -id <suggestionId> [-reviewer <name>] [-force]", runApproveSuggestion},
+	{"reject-suggestion", "reject-suggestion -id <suggestionId> [-reviewer <name>]", runRejectSuggestion},
+	{"rebuild-stats", "rebuild-stats -user <userId> [-dry-run]", runRebuildStats},
+	{"recompute-stats", "recompute-stats [-segments <n>] [-checkpoint <file>]", runRecomputeStats},
+}
+
+// userCache is the lambdas' cache, with REDIS_ADDR set to their Redis tier.
+// Commands that change who a userId belongs to drop its entries from it.
+var userCache cache.Cache
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != os.Args[1] {
+			continue
+		}
+		s, err := store.NewFromSession()
+		if err != nil {
+			log.Fatalf("Failed to create AWS session: %v", err)
+		}
+		userCache, err = cache.FromEnv()
+		if err != nil {
+			log.Fatalf("Failed to create cache: %v", err)
+		}
+		if err := cmd.run(s, os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", cmd.name, err)
+		}
+		return
+	}
+	usage()
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  admin %s\n", cmd.usage)
+	}
+	os.Exit(2)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runMerge(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	from := fs.String("from", "", "duplicate userId to merge and tombstone")
+	to := fs.String("to", "", "userId that receives the data")
+	fs.Parse(args)
+	if *from == "" || *to == "" {
+		return fmt.Errorf("both -from and -to are required")
+	}
+
+	fromUser, err := s.GetUser(*from)
+	if err != nil {
+		return err
+	}
+	result, err := s.MergeUsers(*from, *to)
+	if err != nil {
+		return err
+	}
+	if err := forgetUser(fromUser); err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// forgetUser drops the cached userId of the user's email, so the lambdas
+// look the user up again on the next request.
+func forgetUser(user *store.User) error {
+	if err := userCache.Delete(cache.UserIdKey(user.Email)); err != nil {
+		return fmt.Errorf("failed to drop cached userId of %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+func runUsers(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("users", flag.ExitOnError)
+	all := fs.Bool("all", false, "include merged users")
+	fs.Parse(args)
+
+	users, err := s.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "USERID\tEMAIL\tNAME\tROLE\tSTATUS\tCREATED")
+	for _, user := range users {
+		status := "active"
+		if user.MergedInto != "" {
+			if !*all {
+				continue
+			}
+			status = "merged"
+		} else if user.Disabled {
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			user.UserId, user.Email, user.Name, user.Role, status, user.CreatedAt)
+	}
+	return w.Flush()
+}
+
+func runFind(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("find", flag.ExitOnError)
+	email := fs.String("email", "", "email to look up")
+	userId := fs.String("user", "", "userId to look up")
+	fs.Parse(args)
+
+	switch {
+	case *userId != "":
+		user, err := s.GetUser(*userId)
+		if err != nil {
+			return err
+		}
+		return printJSON(user)
+	case *email != "":
+		users, err := s.FindUsersByEmail(*email)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return store.ErrUserNotFound
+		}
+		return printJSON(users)
+	default:
+		return fmt.Errorf("one of -email or -user is required")
+	}
+}
+
+func runSetRole(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("set-role", flag.ExitOnError)
+	userId := fs.String("user", "", "userId to update")
+	role := fs.String("role", "", "role to assign, empty to clear")
+	fs.Parse(args)
+	if *userId == "" {
+		return fmt.Errorf("-user is required")
+	}
+	return s.SetRole(*userId, *role)
+}
+
+func runDisable(s *store.Store, args []string) error {
+	return setDisabled(s, "disable", args, true)
+}
+
+func runEnable(s *store.Store, args []string) error {
+	return setDisabled(s, "enable", args, false)
+}
+
+func setDisabled(s *store.Store, name string, args []string, disabled bool) error {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	userId := fs.String("user", "", "userId to update")
+	fs.Parse(args)
+	if *userId == "" {
+		return fmt.Errorf("-user is required")
+	}
+	user, err := s.GetUser(*userId)
+	if err != nil {
+		return err
+	}
+	if err := s.SetDisabled(*userId, disabled); err != nil {
+		return err
+	}
+	if err := forgetUser(user); err != nil {
+		return err
+	}
+
+	// Disabled users are taken off the leaderboards. Enabling puts their
+	// scores back from the attempt log.
+	if disabled {
+		removed, err := s.RemoveLeaderboardEntries(*userId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Removed %s from %d leaderboards", *userId, removed)
+		return nil
+	}
+	result, err := rebuildStats(s, *userId, false)
+	if err != nil {
+		return fmt.Errorf("failed to restore the scores: %w", err)
+	}
+	log.Printf("Restored %d totals of %s", result.totals, *userId)
+	return nil
+}
+
+func runGrantFreezes(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("grant-freezes", flag.ExitOnError)
+	userId := fs.String("user", "", "userId to grant streak freezes to")
+	count := fs.Int("count", 1, "number of streak freezes")
+	fs.Parse(args)
+	if *userId == "" || *count <= 0 {
+		return fmt.Errorf("-user and a positive -count are required")
+	}
+	return s.AddStreakFreezes(*userId, *count)
+}
+
+func runExport(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	userId := fs.String("user", "", "userId to export")
+	residency := fs.String("residency", "", "residency whose region holds the user's data")
+	reportsBucket := fs.String("reports-bucket", os.Getenv("REPORTS_BUCKET"), "REPORTS_BUCKET of the reports lambda")
+	out := fs.String("out", "", "file to write to, defaults to stdout")
+	fs.Parse(args)
+	if *userId == "" {
+		return fmt.Errorf("-user is required")
+	}
+	if *reportsBucket == "" {
+		return fmt.Errorf("-reports-bucket is required, the user's reports are part of the export")
+	}
+	if *residency != "" {
+		var err error
+		if s, err = store.NewForResidency(*residency); err != nil {
+			return err
+		}
+	}
+
+	export, err := s.ExportUser(*userId)
+	if err != nil {
+		return err
+	}
+	// The bucket is in the region of the residency, like the tables
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(store.Regions[s.Residency()]),
+	})
+	if err != nil {
+		return err
+	}
+	archive := report.NewArchive(s3.New(sess), *reportsBucket)
+	if export.Reports, err = archive.Export(*userId); err != nil {
+		return err
+	}
+	if *out == "" {
+		return printJSON(export)
+	}
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*out, data, 0600)
+}
+
+// runInvalidateCache drops the cached userIds and leaderboard top lists from
+// the shared cache tier and bumps cache.EpochKey, which makes the words lambda
+// reload its words, packs and events. Containers notice within the local
+// tier's TTL. Without REDIS_ADDR there is no shared tier to reach them.
+func runInvalidateCache(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("invalidate-cache", flag.ExitOnError)
+	fs.Parse(args)
+	if os.Getenv("REDIS_ADDR") == "" {
+		return fmt.Errorf("REDIS_ADDR of the lambdas' shared cache is required")
+	}
+
+	deleted, err := userCache.DeletePrefix(cache.UserIdPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to drop cached userIds: %w", err)
+	}
+	log.Printf("Dropped %d cached userIds", deleted)
+	deleted, err = userCache.DeletePrefix(cache.LeaderboardPrefix)
+	if err != nil {
+		return fmt.Errorf("failed to drop cached leaderboards: %w", err)
+	}
+	log.Printf("Dropped %d cached leaderboards", deleted)
+
+	epoch := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := userCache.Set(cache.EpochKey, epoch, 0); err != nil {
+		return fmt.Errorf("failed to bump the cache epoch: %w", err)
+	}
+	log.Printf("Caches invalidated")
+	return nil
+}
+
+// runPutPack creates or replaces a content pack from a JSON file in the
+// format of store.Pack.
+func runPutPack(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("put-pack", flag.ExitOnError)
+	file := fs.String("file", "", "JSON file describing the pack")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var pack store.Pack
+	if err := json.Unmarshal(data, &pack); err != nil {
+		return err
+	}
+	if pack.PackId == "" || pack.Name == "" || len(pack.Words) == 0 {
+		return fmt.Errorf("packId, name and words are required")
+	}
+	if err := s.PutPack(pack); err != nil {
+		return err
+	}
+	log.Printf("Pack %s stored with %d words", pack.PackId, len(pack.Words))
+	return nil
+}
+
+// runPutGroup creates a classroom group or updates it from a JSON file in
+// the format of store.Group. Members in the file are ignored, see
+// add-group-member.
+func runPutGroup(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("put-group", flag.ExitOnError)
+	file := fs.String("file", "", "JSON file describing the group")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var group store.Group
+	if err := json.Unmarshal(data, &group); err != nil {
+		return err
+	}
+	if group.GroupId == "" || group.Name == "" || group.TeacherId == "" {
+		return fmt.Errorf("groupId, name and teacherId are required")
+	}
+	for _, deckId := range group.Decks {
+		if _, err := s.GetPack(deckId); err != nil {
+			return fmt.Errorf("deck %s: %w", deckId, err)
+		}
+	}
+	if err := s.PutGroup(group); err != nil {
+		return err
+	}
+	log.Printf("Group %s stored with %d decks", group.GroupId, len(group.Decks))
+	return nil
+}
+
+func runAddGroupMember(s *store.Store, args []string) error {
+	groupId, userId, err := groupMemberFlags("add-group-member", args)
+	if err != nil {
+		return err
+	}
+	return s.AddGroupMember(groupId, userId)
+}
+
+// runRemoveGroupMember takes the user out of the group and off the group's
+// leaderboards.
+func runRemoveGroupMember(s *store.Store, args []string) error {
+	groupId, userId, err := groupMemberFlags("remove-group-member", args)
+	if err != nil {
+		return err
+	}
+	return s.RemoveGroupMember(groupId, userId)
+}
+
+func groupMemberFlags(name string, args []string) (string, string, error) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	groupId := fs.String("group", "", "groupId of the group")
+	userId := fs.String("user", "", "userId of the member")
+	fs.Parse(args)
+	if *groupId == "" || *userId == "" {
+		return "", "", fmt.Errorf("both -group and -user are required")
+	}
+	return *groupId, *userId, nil
+}
+
+// runPutEvent creates or replaces a time-limited event from a JSON file in
+// the format of store.Event. The words lambda only picks up changed events
+// after invalidate-cache.
+func runPutEvent(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("put-event", flag.ExitOnError)
+	file := fs.String("file", "", "JSON file describing the event")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var event store.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return err
+	}
+	if event.EventId == "" || event.Name == "" {
+		return fmt.Errorf("eventId and name are required")
+	}
+	startsAt, err := time.Parse(time.RFC3339, event.StartsAt)
+	if err != nil {
+		return fmt.Errorf("invalid startsAt: %w", err)
+	}
+	endsAt, err := time.Parse(time.RFC3339, event.EndsAt)
+	if err != nil {
+		return fmt.Errorf("invalid endsAt: %w", err)
+	}
+	if !endsAt.After(startsAt) {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+	if event.XPMultiplier < 0 {
+		return fmt.Errorf("xpMultiplier must not be negative")
+	}
+	// Stored in UTC like every other timestamp
+	event.StartsAt = startsAt.UTC().Format(time.RFC3339)
+	event.EndsAt = endsAt.UTC().Format(time.RFC3339)
+	if err := s.PutEvent(event); err != nil {
+		return err
+	}
+	log.Printf("Event %s stored, runs %s to %s", event.EventId, event.StartsAt, event.EndsAt)
+	return nil
+}
+
+// runImportWords adds the words of a JSON file in the format of
+// []store.Word. Words that duplicate an existing word or another word of the
+// file, up to case, whitespace and diacritics, are not imported but reported.
+func runImportWords(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("import-words", flag.ExitOnError)
+	file := fs.String("file", "", "JSON file with the words")
+	dryRun := fs.Bool("dry-run", false, "only report conflicts")
+	force := fs.Bool("force", false, "import words despite conflicts, except exact duplicates")
+	fs.Parse(args)
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	var words []store.Word
+	if err := json.Unmarshal(data, &words); err != nil {
+		return err
+	}
+	candidates := make([]string, 0, len(words))
+	for _, word := range words {
+		if word.Word == "" || word.Correct == "" || len(word.Incorrect) == 0 {
+			return fmt.Errorf("word, correct and incorrect are required: %+v", word)
+		}
+		candidates = append(candidates, word.Word)
+	}
+
+	stored, err := s.ListWords()
+	if err != nil {
+		return err
+	}
+	existing := make([]string, 0, len(stored))
+	for _, word := range stored {
+		existing = append(existing, word.Word)
+	}
+
+	conflicts := dedupe.Check(existing, candidates, store.WordsLanguage)
+	rejected := make(map[int]bool, len(conflicts))
+	for _, conflict := range conflicts {
+		// A duplicate would overwrite the stored word, even when forced
+		if !*force || conflict.Reason == dedupe.ReasonDuplicate {
+			rejected[conflict.Index] = true
+		}
+	}
+	var accepted []store.Word
+	for i, word := range words {
+		if !rejected[i] {
+			accepted = append(accepted, word)
+		}
+	}
+
+	if len(conflicts) > 0 {
+		if err := printJSON(conflicts); err != nil {
+			return err
+		}
+	}
+	if *dryRun {
+		log.Printf("%d words would be imported, %d conflicts", len(accepted), len(conflicts))
+		return nil
+	}
+	if err := s.PutWords(accepted); err != nil {
+		return err
+	}
+	log.Printf("Imported %d words, %d conflicts skipped", len(accepted), len(rejected))
+	return nil
+}
+
+func runSuggestions(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("suggestions", flag.ExitOnError)
+	status := fs.String("status", store.SuggestionPending, "status of the suggestions to list")
+	fs.Parse(args)
+
+	suggestions, err := s.ListSuggestions(*status)
+	if err != nil {
+		return err
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return store.CompareTimestamps(suggestions[i].CreatedAt, suggestions[j].CreatedAt) < 0
+	})
+	return printJSON(suggestions)
+}
+
+// runApproveSuggestion adds the suggested word to the Words table. Like
+// import-words it refuses words that clash with a stored one unless -force
+// is given.
+func runApproveSuggestion(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("approve-suggestion", flag.ExitOnError)
+	force := fs.Bool("force", false, "approve despite a conflict, except an exact duplicate")
+	suggestion, reviewer, err := reviewFlags(fs, s, args)
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.ListWords()
+	if err != nil {
+		return err
+	}
+	existing := make([]string, 0, len(stored))
+	for _, word := range stored {
+		existing = append(existing, word.Word)
+	}
+	conflicts := dedupe.Check(existing, []string{suggestion.Word.Word}, store.WordsLanguage)
+	if len(conflicts) > 0 && (!*force || conflicts[0].Reason == dedupe.ReasonDuplicate) {
+		if err := printJSON(conflicts); err != nil {
+			return err
+		}
+		return fmt.Errorf("%q clashes with %q, reject the suggestion instead", conflicts[0].Word, conflicts[0].Existing)
+	}
+
+	return s.ApproveSuggestion(suggestion, reviewer, time.Now())
+}
+
+func runRejectSuggestion(s *store.Store, args []string) error {
+	suggestion, reviewer, err := reviewFlags(flag.NewFlagSet("reject-suggestion", flag.ExitOnError), s, args)
+	if err != nil {
+		return err
+	}
+	return s.RejectSuggestion(suggestion.SuggestionId, reviewer, time.Now())
+}
+
+// reviewFlags parses the flags shared by the review commands and returns the
+// pending suggestion they name and who reviews it.
+func reviewFlags(fs *flag.FlagSet, s *store.Store, args []string) (*store.Suggestion, string, error) {
+	id := fs.String("id", "", "suggestionId to review")
+	reviewer := fs.String("reviewer", os.Getenv("USER"), "name recorded as the reviewer")
+	fs.Parse(args)
+	if *id == "" || *reviewer == "" {
+		return nil, "", fmt.Errorf("-id and -reviewer are required")
+	}
+
+	suggestion, err := s.GetSuggestion(*id)
+	if err != nil {
+		return nil, "", err
+	}
+	if suggestion.Status != store.SuggestionPending {
+		return nil, "", store.ErrSuggestionReviewed
+	}
+	return suggestion, *reviewer, nil
+}
+
+// recomputeCheckpoint is the progress of a recompute-stats run, saved after
+// every scanned page so an interrupted run continues where it stopped.
+type recomputeCheckpoint struct {
+	Segments []segmentProgress `json:"segments"`
+}
+
+type segmentProgress struct {
+	// Cursor is the last userId of the segment that was recomputed
+	Cursor  string `json:"cursor,omitempty"`
+	Done    bool   `json:"done"`
+	Users   int    `json:"users"`
+	Updated int    `json:"updated"`
+}
+
+// runRecomputeStats rebuilds the WordStatistics of every user from the
+// attempt log, e.g. after the success ratio or review schedule formula
+// changed. The users are scanned in parallel segments. If the checkpoint file
+// exists the run is resumed from it and -segments is ignored.
+func runRecomputeStats(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("recompute-stats", flag.ExitOnError)
+	segments := fs.Int("segments", 4, "number of parallel scan segments")
+	file := fs.String("checkpoint", "recompute-stats.json", "file to keep progress in")
+	fs.Parse(args)
+	if *segments <= 0 {
+		return fmt.Errorf("-segments must be positive")
+	}
+
+	checkpoint := &recomputeCheckpoint{Segments: make([]segmentProgress, *segments)}
+	data, err := os.ReadFile(*file)
+	if err == nil {
+		if err := json.Unmarshal(data, checkpoint); err != nil {
+			return fmt.Errorf("invalid checkpoint %s: %w", *file, err)
+		}
+		log.Printf("Resuming from %s", *file)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var mu sync.Mutex
+	save := func(segment int, cursor string, users int, updated int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		progress := &checkpoint.Segments[segment]
+		progress.Cursor = cursor
+		progress.Done = cursor == ""
+		progress.Users += users
+		progress.Updated += updated
+		data, err := json.MarshalIndent(checkpoint, "", "  ")
+		if err != nil {
+			return err
+		}
+		// Write and rename so a crash never leaves a truncated checkpoint
+		if err := os.WriteFile(*file+".tmp", data, 0600); err != nil {
+			return err
+		}
+		return os.Rename(*file+".tmp", *file)
+	}
+
+	total := len(checkpoint.Segments)
+	errs := make([]error, total)
+	var wg sync.WaitGroup
+	for segment := range checkpoint.Segments {
+		if checkpoint.Segments[segment].Done {
+			continue
+		}
+		wg.Add(1)
+		go func(segment int, start string) {
+			defer wg.Done()
+			errs[segment] = s.ScanUserIdSegment(segment, total, start, func(userIds []string, cursor string) error {
+				updated := 0
+				for _, userId := range userIds {
+					result, err := rebuildStats(s, userId, false)
+					if err != nil {
+						return fmt.Errorf("failed to recompute user %s: %w", userId, err)
+					}
+					updated += result.updated
+				}
+				return save(segment, cursor, len(userIds), updated)
+			})
+		}(segment, checkpoint.Segments[segment].Cursor)
+	}
+	wg.Wait()
+
+	users, updated := 0, 0
+	for segment, progress := range checkpoint.Segments {
+		if errs[segment] != nil {
+			return fmt.Errorf("segment %d: %w", segment, errs[segment])
+		}
+		users += progress.Users
+		updated += progress.Updated
+	}
+	log.Printf("Recomputed the word statistics of %d users, %d rows updated", users, updated)
+	return os.Remove(*file)
+}
+
+// runRebuildStats replays the user's attempt log into WordStatistics, XP,
+// leaderboard scores and the streak.
+func runRebuildStats(s *store.Store, args []string) error {
+	fs := flag.NewFlagSet("rebuild-stats", flag.ExitOnError)
+	userId := fs.String("user", "", "userId to rebuild")
+	dryRun := fs.Bool("dry-run", false, "only report what would change")
+	fs.Parse(args)
+	if *userId == "" {
+		return fmt.Errorf("-user is required")
+	}
+
+	result, err := rebuildStats(s, *userId, *dryRun)
+	if err != nil {
+		return err
+	}
+	log.Printf("Rebuilt %d of %d logged words, %d skipped, %d totals repaired", result.updated, result.logged, result.skipped, result.totals)
+	return nil
+}
+
+type rebuildResult struct {
+	logged  int
+	updated int
+	skipped int
+	// totals counts the XP, leaderboard scores and streak repaired
+	totals int
+}
+
+// rebuildStats replays the user's attempt log into WordStatistics with the
+// same projection the results handler uses, so counters, success ratio and
+// review schedule all follow the current formulas. Words whose row counts
+// more attempts than the log holds were practiced before the log existed;
+// only their success ratio is recomputed, replaying them would lose history.
+// Rows a results upload changes meanwhile are left to it. The user's totals
+// are repaired with repairTotals.
+func rebuildStats(s *store.Store, userId string, dryRun bool) (rebuildResult, error) {
+	var result rebuildResult
+	attempts, err := s.ListAttempts(userId)
+	if err != nil {
+		return result, err
+	}
+	rebuilt, err := projection.Replay(attempts)
+	if err != nil {
+		return result, err
+	}
+	stats, err := s.ListWordStatistics(userId)
+	if err != nil {
+		return result, err
+	}
+	current := make(map[string]store.WordStatistics, len(stats))
+	for _, stat := range stats {
+		current[stat.Word] = stat
+	}
+	result.logged = len(rebuilt)
+
+	for _, existing := range current {
+		stat, logged := rebuilt[existing.Word]
+		if logged && existing.Attempts <= stat.Attempts {
+			continue
+		}
+		if logged {
+			log.Printf("Skipping %s of %s: %d attempts stored, %d logged", existing.Word, userId, existing.Attempts, stat.Attempts)
+		}
+		result.skipped++
+		if dryRun {
+			continue
+		}
+		updated, err := s.RecomputeSuccessRatio(existing)
+		if err != nil {
+			return result, fmt.Errorf("failed to recompute %s: %w", existing.Word, err)
+		}
+		if updated {
+			result.updated++
+		}
+	}
+
+	for word, stat := range rebuilt {
+		existing, exists := current[word]
+		if exists && (existing.Attempts > stat.Attempts || existing == *stat) {
+			continue
+		}
+		if dryRun {
+			log.Printf("Would rebuild %s of %s", word, userId)
+			result.updated++
+			continue
+		}
+		updated, err := s.PutWordStatistics(*stat, existing.Attempts)
+		if err != nil {
+			return result, fmt.Errorf("failed to rebuild %s: %w", word, err)
+		}
+		if updated {
+			result.updated++
+		}
+	}
+
+	result.totals, err = repairTotals(s, userId, attempts, dryRun)
+	return result, err
+}
+
+// repairTotals raises the user's XP, leaderboard scores and streak to what
+// the attempt log adds up to, and returns how many it repaired. Scores are
+// left alone where the user must not rank, see onBoard. They are
+// never lowered: XP and scores beyond the log were earned before the log
+// existed, and a longer streak was kept alive by freezes that aren't logged,
+// see projection.Streak. The user is read after the log, so anything a
+// concurrent upload recorded is in it too and never added twice.
+func repairTotals(s *store.Store, userId string, attempts []store.Attempt, dryRun bool) (int, error) {
+	user, err := s.GetUser(userId)
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+
+	if xp := projection.XP(attempts); xp > user.XP {
+		log.Printf("XP of %s: %d stored, %d logged", userId, user.XP, xp)
+		repaired++
+		if !dryRun {
+			if _, err := s.AddXP(userId, xp-user.XP); err != nil {
+				return repaired, fmt.Errorf("failed to repair xp: %w", err)
+			}
+		}
+	}
+
+	for board, score := range projection.Boards(attempts) {
+		if !onBoard(user, board) {
+			continue
+		}
+		entry, err := s.GetLeaderboardEntry(board, userId)
+		if err != nil {
+			return repaired, err
+		}
+		stored := 0
+		if entry != nil {
+			stored = entry.Score
+		}
+		if score <= stored {
+			continue
+		}
+		log.Printf("Score of %s on %s: %d stored, %d logged", userId, board, stored, score)
+		repaired++
+		if !dryRun {
+			if err := s.AddLeaderboardScore(board, userId, user.Name, score-stored); err != nil {
+				return repaired, fmt.Errorf("failed to repair %s: %w", board, err)
+			}
+		}
+	}
+
+	replayed, err := projection.Streak(attempts, user.VacationFrom, user.VacationTo)
+	if err != nil {
+		return repaired, err
+	}
+	next := user.State
+	if replayed.LastPracticeDate > next.LastPracticeDate {
+		next.Current = replayed.Current
+		next.LastPracticeDate = replayed.LastPracticeDate
+	}
+	if replayed.Longest > next.Longest {
+		next.Longest = replayed.Longest
+	}
+	if next != user.State {
+		log.Printf("Streak of %s: %+v stored, %+v logged", userId, user.State, replayed)
+		repaired++
+		if !dryRun {
+			err := s.SaveStreak(userId, user.State, next)
+			if err != nil && err != store.ErrStreakConflict {
+				return repaired, fmt.Errorf("failed to repair streak: %w", err)
+			}
+		}
+	}
+	return repaired, nil
+}
+
+// onBoard reports whether the user ranks on the board: disabled and merged
+// users rank nowhere, and group boards only rank the group's members.
+func onBoard(user *store.User, board string) bool {
+	if user.Disabled || user.MergedInto != "" {
+		return false
+	}
+	groupId := store.BoardGroup(board)
+	if groupId == "" {
+		return true
+	}
+	for _, member := range user.Groups {
+		if member == groupId {
+			return true
+		}
+	}
+	return false
+}
@@ -7,5 +7,7 @@ require (
 	github.com/aws/aws-sdk-go v1.55.5
 	github.com/go-resty/resty/v2 v2.16.2
 	github.com/golang-jwt/jwt/v4 v4.5.1
+	github.com/gomodule/redigo v1.9.2
 	github.com/google/uuid v1.6.0
+	golang.org/x/text v0.16.0
 )
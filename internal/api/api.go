@@ -0,0 +1,52 @@
+// Package api holds what the lambdas behind API Gateway have in common:
+// resolving the calling user and writing JSON responses.
+package api
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/identity"
+	"hpmaster/internal/store"
+)
+
+// JSON responds 200 with v as the body.
+func JSON(v interface{}) (events.APIGatewayProxyResponse, error) {
+	responseBody, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Error marshalling response: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Body:       string(responseBody),
+	}, nil
+}
+
+// Caller resolves the user making the request, see identity.ExtractEmail. If
+// that fails the response to send back is returned instead.
+func Caller(s *store.Store, event events.APIGatewayProxyRequest) (*store.User, events.APIGatewayProxyResponse) {
+	userEmail, err := identity.ExtractEmail(event)
+	if err != nil {
+		return nil, events.APIGatewayProxyResponse{StatusCode: 401, Body: err.Error()}
+	}
+	user, err := s.FindUserByEmail(*userEmail)
+	if err != nil {
+		return nil, UserError(err)
+	}
+	return user, events.APIGatewayProxyResponse{}
+}
+
+// UserError is the response to a failed lookup of the calling user: 403 if
+// the account is disabled, 400 otherwise.
+func UserError(err error) events.APIGatewayProxyResponse {
+	if err == store.ErrUserDisabled {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "Account disabled"}
+	}
+	if err != store.ErrUserNotFound {
+		log.Printf("Error getting user: %v", err)
+	}
+	return events.APIGatewayProxyResponse{StatusCode: 400, Body: "User not found"}
+}
@@ -0,0 +1,40 @@
+package api_test
+
+import (
+	"errors"
+	"testing"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+func TestJSON(t *testing.T) {
+	response, err := api.JSON(map[string]int{"xp": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.StatusCode != 200 || response.Body != `{"xp":10}` {
+		t.Errorf("got %d %q", response.StatusCode, response.Body)
+	}
+
+	response, _ = api.JSON(func() {})
+	if response.StatusCode != 500 {
+		t.Errorf("unmarshallable value: got %d, want 500", response.StatusCode)
+	}
+}
+
+func TestUserError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{store.ErrUserDisabled, 403},
+		{store.ErrUserNotFound, 400},
+		{errors.New("throttled"), 400},
+	}
+	for _, tt := range tests {
+		if got := api.UserError(tt.err).StatusCode; got != tt.want {
+			t.Errorf("UserError(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package cache is the lookup cache shared by the lambdas.
+//
+// Every container keeps an in-memory tier. With REDIS_ADDR set (e.g. an
+// ElastiCache endpoint) a Redis tier sits behind it, so entries written by
+// one container are seen by the others.
+package cache
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Cache interface {
+	// Get returns the value stored under key and whether there was one.
+	Get(key string) (string, bool, error)
+	// Set stores value under key for ttl, 0 meaning no expiry.
+	Set(key string, value string, ttl time.Duration) error
+	Delete(key string) error
+	// DeletePrefix deletes every key starting with prefix and returns how
+	// many there were.
+	DeletePrefix(prefix string) (int, error)
+}
+
+// UserIdPrefix starts the keys mapping a user's email to the userId, see
+// UserIdKey.
+const UserIdPrefix = "userId:"
+
+// UserIdKey is the key the words lambda caches the userId of email under.
+// Whatever disables or merges the user must delete it.
+func UserIdKey(email string) string {
+	return UserIdPrefix + email
+}
+
+// LeaderboardPrefix starts the keys the leaderboards lambda caches top lists
+// under.
+const LeaderboardPrefix = "leaderboard:"
+
+// EpochKey holds when the caches were last invalidated. Lambdas that keep
+// data in memory outside this cache, like the words lambda's word list,
+// reload it when the epoch changes. It takes the shared tier to reach other
+// containers, see invalidate-cache in cmd/admin.
+const EpochKey = "cacheEpoch"
+
+// DefaultLocalTTL bounds how long the in-memory tier of a two-tier cache may
+// serve an entry that another container changed in Redis.
+const DefaultLocalTTL = time.Minute
+
+// FromEnv returns an in-memory cache, or a two-tier cache backed by Redis if
+// REDIS_ADDR is set.
+func FromEnv() (Cache, error) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		return NewMemory(), nil
+	}
+	localTTL := DefaultLocalTTL
+	if ttl := os.Getenv("CACHE_LOCAL_TTL"); ttl != "" {
+		var err error
+		localTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewTwoTier(NewMemory(), NewRedis(addr), localTTL), nil
+}
+
+type entry struct {
+	value     string
+	expiresAt time.Time
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// Memory is a cache local to the container.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]entry)}
+}
+
+func (m *Memory) Get(key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, exists := m.entries[key]
+	if !exists || e.expired(time.Now()) {
+		delete(m.entries, key)
+		return "", false, nil
+	}
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(key string, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	m.entries[key] = e
+	return nil
+}
+
+func (m *Memory) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) DeletePrefix(prefix string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deleted := 0
+	for key := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(m.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// TwoTier answers reads from the local tier and falls back to the shared
+// one. Writes go to both.
+type TwoTier struct {
+	local    Cache
+	shared   Cache
+	localTTL time.Duration
+}
+
+func NewTwoTier(local Cache, shared Cache, localTTL time.Duration) *TwoTier {
+	return &TwoTier{local: local, shared: shared, localTTL: localTTL}
+}
+
+func (t *TwoTier) Get(key string) (string, bool, error) {
+	if value, ok, err := t.local.Get(key); err == nil && ok {
+		return value, true, nil
+	}
+	value, ok, err := t.shared.Get(key)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return value, true, t.local.Set(key, value, t.localTTL)
+}
+
+func (t *TwoTier) Set(key string, value string, ttl time.Duration) error {
+	if err := t.shared.Set(key, value, ttl); err != nil {
+		return err
+	}
+	return t.local.Set(key, value, t.localTTLFor(ttl))
+}
+
+func (t *TwoTier) Delete(key string) error {
+	if err := t.shared.Delete(key); err != nil {
+		return err
+	}
+	return t.local.Delete(key)
+}
+
+func (t *TwoTier) DeletePrefix(prefix string) (int, error) {
+	deleted, err := t.shared.DeletePrefix(prefix)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := t.local.DeletePrefix(prefix); err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+func (t *TwoTier) localTTLFor(ttl time.Duration) time.Duration {
+	if ttl > 0 && ttl < t.localTTL {
+		return ttl
+	}
+	return t.localTTL
+}
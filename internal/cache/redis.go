@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Redis is a cache shared by all containers.
+type Redis struct {
+	pool *redis.Pool
+}
+
+func NewRedis(addr string) *Redis {
+	return &Redis{pool: &redis.Pool{
+		MaxIdle:     4,
+		IdleTimeout: 5 * time.Minute,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialConnectTimeout(time.Second),
+				redis.DialReadTimeout(time.Second),
+				redis.DialWriteTimeout(time.Second))
+		},
+	}}
+}
+
+func (r *Redis) Get(key string) (string, bool, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	value, err := redis.String(conn.Do("GET", key))
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(key string, value string, ttl time.Duration) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	var err error
+	if ttl > 0 {
+		_, err = conn.Do("SET", key, value, "PX", ttl.Milliseconds())
+	} else {
+		_, err = conn.Do("SET", key, value)
+	}
+	return err
+}
+
+func (r *Redis) Delete(key string) error {
+	conn := r.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("DEL", key)
+	return err
+}
+
+// globEscaper escapes the characters SCAN MATCH treats as patterns.
+var globEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+func (r *Redis) DeletePrefix(prefix string) (int, error) {
+	conn := r.pool.Get()
+	defer conn.Close()
+	pattern := globEscaper.Replace(prefix) + "*"
+	deleted := 0
+	cursor := int64(0)
+	for {
+		reply, err := redis.Values(conn.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 1000))
+		if err != nil {
+			return deleted, err
+		}
+		if cursor, err = redis.Int64(reply[0], nil); err != nil {
+			return deleted, err
+		}
+		keys, err := redis.Strings(reply[1], nil)
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := redis.Int(conn.Do("DEL", redis.Args{}.AddFlat(keys)...))
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+		if cursor == 0 {
+			return deleted, nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package dedupe detects words that only differ from each other by case,
+// whitespace or diacritics. Storing such near-duplicates splits the
+// statistics of a word over two entries. Letters that are letters of their
+// own in the words' language, like å, ä and ö in Swedish, are not taken for
+// diacritics: "får" and "far" are different words.
+package dedupe
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Conflict reasons, from the weakest to the strongest normalization needed
+// to make two words equal.
+const (
+	ReasonDuplicate  = "duplicate"
+	ReasonWhitespace = "whitespace"
+	ReasonCase       = "case"
+	ReasonDiacritics = "diacritics"
+)
+
+type Conflict struct {
+	// Index is the position of Word among the checked candidates.
+	Index int    `json:"index"`
+	Word  string `json:"word"`
+	// Existing is the stored word, or the earlier candidate, Word clashes with.
+	Existing string `json:"existing"`
+	Reason   string `json:"reason"`
+}
+
+const (
+	accented = "àáâãäåāăąçćĉċčďđèéêëēĕėęěĝğġģĥħìíîïĩīĭįıĵķĺļľŀłñńņňòóôõöøōŏőŕŗřśŝşšţťŧùúûüũūŭůűųŵýÿŷźżž"
+	base     = "aaaaaaaaacccccddeeeeeeeeegggghhiiiiiiiiijklllllnnnnooooooooorrrsssstttuuuuuuuuuuwyyyzzz"
+)
+
+var diacritics = strings.NewReplacer(pairs(accented, base)...)
+
+func pairs(from string, to string) []string {
+	var oldnew []string
+	toRunes := []rune(to)
+	for i, r := range []rune(from) {
+		oldnew = append(oldnew, string(r), string(toRunes[i]))
+	}
+	return oldnew
+}
+
+// alphabets lists, by ISO 639-1 code, the letters of a language that look
+// like accented letters but are letters of their own.
+var alphabets = map[string]string{
+	"sv": "åäö",
+	"fi": "åäö",
+	"da": "æøå",
+	"nb": "æøå",
+	"nn": "æøå",
+	"no": "æøå",
+}
+
+// strokes are letters that Unicode doesn't decompose into a base letter and
+// a mark, mapped to their base letter.
+var strokes = map[rune]rune{
+	'đ': 'd', 'ħ': 'h', 'ı': 'i', 'ł': 'l', 'ŀ': 'l', 'ø': 'o', 'ŧ': 't',
+}
+
+// Key returns the form under which near-duplicates of word in the language
+// compare equal. The word is composed (NFC) first, so a letter typed as a
+// base letter and a combining mark matches the precomposed one. Every letter
+// that isn't one of the language's own is then decomposed (NFD) and its
+// marks dropped.
+func Key(word string, language string) string {
+	own := alphabets[language]
+	var key strings.Builder
+	for _, r := range norm.NFC.String(strings.ToLower(collapseSpace(word))) {
+		if strings.ContainsRune(own, r) {
+			key.WriteRune(r)
+			continue
+		}
+		if base, exists := strokes[r]; exists {
+			key.WriteRune(base)
+			continue
+		}
+		for _, d := range norm.NFD.String(string(r)) {
+			if !unicode.Is(unicode.Mn, d) {
+				key.WriteRune(d)
+			}
+		}
+	}
+	return key.String()
+}
+
+// StripDiacritics replaces accented lowercase latin letters by their base
+// letter, whatever the language. Grading uses it; it only knows precomposed
+// letters, unlike Key.
+func StripDiacritics(s string) string {
+	return diacritics.Replace(s)
+}
+
+func collapseSpace(word string) string {
+	return strings.Join(strings.Fields(word), " ")
+}
+
+// Check returns a conflict for every candidate that matches an existing word
+// or an earlier candidate. All words are in the given language.
+func Check(existing []string, candidates []string, language string) []Conflict {
+	known := make(map[string]string, len(existing)+len(candidates))
+	for _, word := range existing {
+		known[Key(word, language)] = word
+	}
+
+	var conflicts []Conflict
+	for i, word := range candidates {
+		key := Key(word, language)
+		if match, exists := known[key]; exists {
+			conflicts = append(conflicts, Conflict{Index: i, Word: word, Existing: match, Reason: reason(word, match)})
+			continue
+		}
+		known[key] = word
+	}
+	return conflicts
+}
+
+func reason(a string, b string) string {
+	// The same word, only typed with combining marks, is a duplicate
+	a, b = norm.NFC.String(a), norm.NFC.String(b)
+	switch {
+	case a == b:
+		return ReasonDuplicate
+	case collapseSpace(a) == collapseSpace(b):
+		return ReasonWhitespace
+	case strings.ToLower(collapseSpace(a)) == strings.ToLower(collapseSpace(b)):
+		return ReasonCase
+	default:
+		return ReasonDiacritics
+	}
+}
@@ -0,0 +1,39 @@
+package dedupe_test
+
+import (
+	"reflect"
+	"testing"
+
+	"hpmaster/internal/dedupe"
+)
+
+func TestCheck(t *testing.T) {
+	existing := []string{"far", "arkaisk", "cafe", "gnällspik"}
+	candidates := []string{"får", "Arkaisk", "café", "far", "ålderdomlig", "alderdomlig", "cafe\u0301", "gna\u0308llspik", "gnallspik"}
+	want := map[string][]dedupe.Conflict{
+		// å, ä and ö are letters of their own in Swedish
+		"sv": {
+			{Index: 1, Word: "Arkaisk", Existing: "arkaisk", Reason: dedupe.ReasonCase},
+			{Index: 2, Word: "café", Existing: "cafe", Reason: dedupe.ReasonDiacritics},
+			{Index: 3, Word: "far", Existing: "far", Reason: dedupe.ReasonDuplicate},
+			// Typed with combining marks
+			{Index: 6, Word: "cafe\u0301", Existing: "cafe", Reason: dedupe.ReasonDiacritics},
+			{Index: 7, Word: "gna\u0308llspik", Existing: "gnällspik", Reason: dedupe.ReasonDuplicate},
+		},
+		"en": {
+			{Index: 0, Word: "får", Existing: "far", Reason: dedupe.ReasonDiacritics},
+			{Index: 1, Word: "Arkaisk", Existing: "arkaisk", Reason: dedupe.ReasonCase},
+			{Index: 2, Word: "café", Existing: "cafe", Reason: dedupe.ReasonDiacritics},
+			{Index: 3, Word: "far", Existing: "far", Reason: dedupe.ReasonDuplicate},
+			{Index: 5, Word: "alderdomlig", Existing: "ålderdomlig", Reason: dedupe.ReasonDiacritics},
+			{Index: 6, Word: "cafe\u0301", Existing: "cafe", Reason: dedupe.ReasonDiacritics},
+			{Index: 7, Word: "gna\u0308llspik", Existing: "gnällspik", Reason: dedupe.ReasonDuplicate},
+			{Index: 8, Word: "gnallspik", Existing: "gnällspik", Reason: dedupe.ReasonDiacritics},
+		},
+	}
+	for language, conflicts := range want {
+		if got := dedupe.Check(existing, candidates, language); !reflect.DeepEqual(got, conflicts) {
+			t.Errorf("%s: got %+v, want %+v", language, got, conflicts)
+		}
+	}
+}
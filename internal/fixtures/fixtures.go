@@ -0,0 +1,81 @@
+// Package fixtures holds canned datasets for the tests of the core
+// algorithms, and the golden file helper they compare their output with.
+//
+// The data is fixed, including the clock (Now) and the random source (Rand),
+// so the same code always produces the same output. A change in a golden
+// file therefore is a change in behavior, to be reviewed as such.
+package fixtures
+
+import (
+	"math/rand"
+	"time"
+
+	"hpmaster/internal/grading"
+	"hpmaster/internal/store"
+)
+
+const UserId = "fixture-user"
+
+// Now is the fixed time the datasets are relative to.
+var Now = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// Rand returns a random source that yields the same sequence for a seed.
+func Rand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// Words returns the word list. Every call returns a fresh copy, so tests
+// may modify it.
+func Words() []store.Word {
+	strip := true
+	return []store.Word{
+		{Word: "arkaisk", Correct: "ålderdomlig", Incorrect: []string{"byggnadsteknisk", "envis", "ordnad", "krigisk"}, Difficulty: "hard", Category: "adjektiv"},
+		{Word: "banal", Correct: "alldaglig", Incorrect: []string{"märklig", "känslig", "tydlig", "ovanlig"}, Difficulty: "easy", Category: "adjektiv"},
+		{Word: "eftertrakta", Correct: "önska sig", Incorrect: []string{"efterlikna", "förfölja", "undersöka", "tvivla på"}, Difficulty: "medium", Category: "verb", Synonyms: []string{"åtrå"}},
+		{Word: "fiasko", Correct: "misslyckande", Incorrect: []string{"fest", "förvirring", "framgång", "flykt"}, Difficulty: "easy", Category: "substantiv", Synonyms: []string{"debacle"}},
+		{Word: "förhala", Correct: "fördröja", Incorrect: []string{"förstora", "förklara", "förvandla", "förorsaka"}, Difficulty: "hard", Category: "verb", Synonyms: []string{"dra ut på"}},
+		{Word: "idog", Correct: "flitig", Incorrect: []string{"envis", "klok", "slarvig", "glad"}, Difficulty: "easy", Category: "adjektiv"},
+		{Word: "kverulant", Correct: "gnällspik", Incorrect: []string{"kvacksalvare", "spelare", "frågvis person", "sällskapsmänniska"}, Difficulty: "hard", Category: "substantiv", Grading: &grading.Override{StripDiacritics: &strip}},
+		{Word: "pragmatisk", Correct: "praktisk", Incorrect: []string{"principfast", "pratsam", "högtidlig", "oförsiktig"}, Difficulty: "medium", Category: "adjektiv"},
+		{Word: "sporadisk", Correct: "enstaka", Incorrect: []string{"regelbunden", "sportslig", "spröd", "spridd"}, Difficulty: "medium", Category: "adjektiv", Synonyms: []string{"tillfällig"}},
+		{Word: "utopi", Correct: "orealistisk idealbild", Incorrect: []string{"landsflykt", "ödemark", "framtidsprognos", "missförstånd"}, Difficulty: "medium", Category: "substantiv"},
+		{Word: "vedergällning", Correct: "hämnd", Incorrect: []string{"ersättning", "förlåtelse", "vedertagen sed", "vedermöda"}, Difficulty: "hard", Category: "substantiv"},
+		{Word: "värna", Correct: "skydda", Incorrect: []string{"varna", "vårda", "vänta", "värma"}, Category: "verb"},
+	}
+}
+
+// WordStatistics returns UserId's statistics at Now, keyed by word. Some
+// words are due, some are scheduled later and the rest were never practiced.
+func WordStatistics() map[string]store.WordStatistics {
+	stats := []store.WordStatistics{
+		stat("arkaisk", 6, 1, 1, -2),
+		stat("banal", 10, 10, 16, 9),
+		stat("eftertrakta", 4, 2, 1, 0),
+		stat("förhala", 8, 3, 2, -5),
+		stat("idog", 5, 5, 8, 3),
+		stat("pragmatisk", 3, 0, 1, -1),
+		stat("vedergällning", 2, 1, 1, 1),
+	}
+	byWord := make(map[string]store.WordStatistics, len(stats))
+	for _, s := range stats {
+		byWord[s.Word] = s
+	}
+	return byWord
+}
+
+// stat is a row last practiced intervalDays before its next review, which
+// is dueInDays from Now (negative when overdue).
+func stat(word string, attempts int, success int, intervalDays int, dueInDays int) store.WordStatistics {
+	next := Now.AddDate(0, 0, dueInDays)
+	return store.WordStatistics{
+		UserId:          UserId,
+		Word:            word,
+		Attempts:        attempts,
+		Success:         success,
+		SuccessRatio:    store.SuccessRatio(success, attempts),
+		IntervalDays:    intervalDays,
+		NextReviewAt:    next.Format(time.RFC3339),
+		LastPracticedAt: next.AddDate(0, 0, -intervalDays).Format(time.RFC3339),
+		FirstSeenAt:     Now.AddDate(0, -1, 0).Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,99 @@
+// Package grading decides whether a typed answer (spelling mode) is correct.
+//
+// Both the answer and the accepted answers are normalized by Rules before
+// they are compared. The default rules are configured through the
+// ANSWER_RULES environment variable of the words lambda and a word can
+// override them with its own Override.
+package grading
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"hpmaster/internal/dedupe"
+)
+
+type Rules struct {
+	// Trim removes leading and trailing whitespace and collapses inner runs
+	// of whitespace into a single space.
+	Trim bool `json:"trim"`
+	// FoldCase ignores the difference between upper and lower case.
+	FoldCase bool `json:"foldCase"`
+	// StripDiacritics accepts e.g. "a" for "ä". Only lowercase letters are
+	// stripped, so it is normally combined with FoldCase.
+	StripDiacritics bool `json:"stripDiacritics"`
+}
+
+// Override is stored on a Word. Rules left nil keep the configured value.
+type Override struct {
+	Trim            *bool `json:"trim,omitempty"`
+	FoldCase        *bool `json:"foldCase,omitempty"`
+	StripDiacritics *bool `json:"stripDiacritics,omitempty"`
+}
+
+var DefaultRules = Rules{
+	Trim:     true,
+	FoldCase: true,
+}
+
+// LoadRules parses rules from JSON. Fields missing from the JSON keep their
+// DefaultRules values; an empty string yields DefaultRules.
+func LoadRules(config string) (Rules, error) {
+	rules := DefaultRules
+	if config == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(config), &rules); err != nil {
+		return Rules{}, fmt.Errorf("invalid answer rules: %w", err)
+	}
+	return rules, nil
+}
+
+// With returns the rules with the word's override applied.
+func (r Rules) With(override *Override) Rules {
+	if override == nil {
+		return r
+	}
+	if override.Trim != nil {
+		r.Trim = *override.Trim
+	}
+	if override.FoldCase != nil {
+		r.FoldCase = *override.FoldCase
+	}
+	if override.StripDiacritics != nil {
+		r.StripDiacritics = *override.StripDiacritics
+	}
+	return r
+}
+
+func (r Rules) Normalize(s string) string {
+	if r.Trim {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	if r.FoldCase {
+		s = strings.ToLower(s)
+	}
+	if r.StripDiacritics {
+		s = dedupe.StripDiacritics(s)
+	}
+	return s
+}
+
+// Matches reports whether answer equals correct or one of the accepted
+// synonyms after normalization.
+func (r Rules) Matches(answer string, correct string, synonyms []string) bool {
+	answer = r.Normalize(answer)
+	if answer == "" {
+		return false
+	}
+	if answer == r.Normalize(correct) {
+		return true
+	}
+	for _, synonym := range synonyms {
+		if answer == r.Normalize(synonym) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package grading_test
+
+import (
+	"strings"
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/grading"
+)
+
+func TestNormalize(t *testing.T) {
+	inputs := []string{"  Önska   sig ", "ÅLDERDOMLIG", "gnällspik", "Dra\tut\npå", ""}
+	rules := map[string]grading.Rules{
+		"none":    {},
+		"default": grading.DefaultRules,
+		"all":     {Trim: true, FoldCase: true, StripDiacritics: true},
+	}
+	normalized := make(map[string]map[string]string)
+	for name, r := range rules {
+		normalized[name] = make(map[string]string)
+		for _, input := range inputs {
+			normalized[name][input] = r.Normalize(input)
+		}
+	}
+	fixtures.Golden(t, "normalize", normalized)
+}
+
+// TestMatches grades typical answers to every fixture word with the default
+// rules and the word's override, as the words lambda does.
+func TestMatches(t *testing.T) {
+	type graded struct {
+		Answer    string `json:"answer"`
+		IsCorrect bool   `json:"isCorrect"`
+	}
+	results := make(map[string][]graded)
+	for _, word := range fixtures.Words() {
+		rules := grading.DefaultRules.With(word.Grading)
+		answers := []string{
+			word.Correct,
+			strings.ToUpper(word.Correct),
+			"  " + word.Correct + " ",
+			stripAll(word.Correct),
+			word.Incorrect[0],
+			"",
+		}
+		answers = append(answers, word.Synonyms...)
+		for _, answer := range answers {
+			results[word.Word] = append(results[word.Word], graded{
+				Answer:    answer,
+				IsCorrect: rules.Matches(answer, word.Correct, word.Synonyms),
+			})
+		}
+	}
+	fixtures.Golden(t, "matches", results)
+}
+
+func TestLoadRules(t *testing.T) {
+	rules, err := grading.LoadRules(`{"stripDiacritics": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := grading.Rules{Trim: true, FoldCase: true, StripDiacritics: true}
+	if rules != want {
+		t.Errorf("got %+v, want %+v", rules, want)
+	}
+	if _, err := grading.LoadRules("{"); err == nil {
+		t.Error("invalid JSON was accepted")
+	}
+}
+
+func stripAll(s string) string {
+	return strings.NewReplacer("å", "a", "ä", "a", "ö", "o").Replace(s)
+}
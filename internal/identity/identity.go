@@ -0,0 +1,106 @@
+// Package identity extracts the caller identity that the API Gateway
+// authorizer attaches to incoming requests.
+//
+// Direct invocations have no authorizer in front of them. Console test
+// invocations of a deployed function pass the identity the way the
+// authorizer would, in the test event:
+//
+//	"requestContext": {"authorizer": {"email": "user@example.com"}}
+//
+// Local runs under SAM and Go tests can use the development identity
+// instead, see DevEmail.
+package identity
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DevIdentityHeader carries the caller's email in development mode, see
+// DevEmail.
+const DevIdentityHeader = "X-Dev-Email"
+
+// devMode reports whether DEV_IDENTITY=true, which is only honored when
+// running under SAM local (AWS_SAM_LOCAL=true). A deployed stage never honors
+// it, since it lets any caller pick an identity. The environment is read on
+// every call, so tests can set both variables with os.Setenv.
+func devMode() bool {
+	return os.Getenv("DEV_IDENTITY") == "true" && os.Getenv("AWS_SAM_LOCAL") == "true"
+}
+
+func init() {
+	if devMode() {
+		log.Printf("WARNING: DEV_IDENTITY is on, any caller can pick an identity with the %s header", DevIdentityHeader)
+	} else if os.Getenv("DEV_IDENTITY") == "true" {
+		log.Printf("WARNING: DEV_IDENTITY is ignored outside of SAM local")
+	}
+}
+
+// ExtractEmail returns the caller's email from the authorizer context, or
+// in development mode the one given by DevEmail.
+func ExtractEmail(event events.APIGatewayProxyRequest) (*string, error) {
+	userEmail, err := ExtractAuthorizerEmail(event)
+	if err != nil {
+		if email, ok := DevEmail(event); ok {
+			return &email, nil
+		}
+	}
+	return userEmail, err
+}
+
+// ExtractAuthorizerEmail returns the caller's email from the authorizer
+// context only, it never accepts a development identity. Admin endpoints use
+// it.
+func ExtractAuthorizerEmail(event events.APIGatewayProxyRequest) (*string, error) {
+	var userEmail string
+	authorizer := event.RequestContext.Authorizer
+
+	if email, ok := authorizer["email"].(string); ok {
+		userEmail = email
+	} else if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		// Handle custom claims (if your Authorizer outputs claims in Payload V2.0)
+		if emailClaim, exists := claims["email"].(string); exists {
+			userEmail = emailClaim
+		} else {
+			return nil, errors.New("Unauthorized: Email not found")
+		}
+	} else {
+		return nil, errors.New("Unauthorized")
+	}
+	return &userEmail, nil
+}
+
+// DevEmail returns the email of a direct invocation without an Authorizer
+// context, such as a test or a console test event. The email is taken from
+// the X-Dev-Email header or requestContext.identity.user. It is only
+// accepted in development mode, see devMode.
+func DevEmail(event events.APIGatewayProxyRequest) (string, bool) {
+	if !devMode() || event.RequestContext.Authorizer != nil {
+		return "", false
+	}
+	email := event.RequestContext.Identity.User
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, DevIdentityHeader) {
+			email = value
+		}
+	}
+	if email == "" {
+		return "", false
+	}
+	log.Printf("Using development identity %s", email)
+	return email, true
+}
+
+// DeviceId returns the X-Device-Id header sent by the app, or "" if absent.
+func DeviceId(event events.APIGatewayProxyRequest) string {
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, "X-Device-Id") {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package identity_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/identity"
+)
+
+func TestExtractEmail(t *testing.T) {
+	authorized := events.APIGatewayProxyRequest{}
+	authorized.RequestContext.Authorizer = map[string]interface{}{"email": "user@example.com"}
+	direct := events.APIGatewayProxyRequest{Headers: map[string]string{"x-dev-email": "dev@example.com"}}
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		event events.APIGatewayProxyRequest
+		want  string
+	}{
+		{"authorizer", nil, authorized, "user@example.com"},
+		{"authorizer wins in dev mode", map[string]string{"DEV_IDENTITY": "true", "AWS_SAM_LOCAL": "true"}, authorized, "user@example.com"},
+		{"dev mode", map[string]string{"DEV_IDENTITY": "true", "AWS_SAM_LOCAL": "true"}, direct, "dev@example.com"},
+		{"dev mode outside SAM local", map[string]string{"DEV_IDENTITY": "true"}, direct, ""},
+		{"no identity", nil, direct, ""},
+	}
+	for _, test := range tests {
+		os.Unsetenv("DEV_IDENTITY")
+		os.Unsetenv("AWS_SAM_LOCAL")
+		for name, value := range test.env {
+			os.Setenv(name, value)
+		}
+		got := ""
+		if email, err := identity.ExtractEmail(test.event); err == nil {
+			got = *email
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+	os.Unsetenv("DEV_IDENTITY")
+	os.Unsetenv("AWS_SAM_LOCAL")
+}
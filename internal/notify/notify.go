@@ -0,0 +1,72 @@
+// Package notify hands user notifications (e.g. a received gift) to whatever
+// delivers them. With NOTIFICATION_TOPIC_ARN set they are published to SNS,
+// where a subscriber can push them to the user's registered devices;
+// otherwise they are only logged.
+package notify
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sns"
+
+	"hpmaster/internal/store"
+)
+
+type Notification struct {
+	UserId string `json:"userId"`
+	// Type tells the app how to render the notification, e.g. "gift".
+	Type    string      `json:"type"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// FromEnv returns the SNS notifier if NOTIFICATION_TOPIC_ARN is set and the
+// logging one otherwise.
+func FromEnv() (Notifier, error) {
+	topicArn := os.Getenv("NOTIFICATION_TOPIC_ARN")
+	if topicArn == "" {
+		return LogNotifier{}, nil
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(store.Region),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SNSNotifier{svc: sns.New(sess), topicArn: topicArn}, nil
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(n Notification) error {
+	log.Printf("Notification for %s: %s", n.UserId, n.Message)
+	return nil
+}
+
+type SNSNotifier struct {
+	svc      *sns.SNS
+	topicArn string
+}
+
+func (s *SNSNotifier) Notify(n Notification) error {
+	message, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	_, err = s.svc.Publish(&sns.PublishInput{
+		TopicArn: aws.String(s.topicArn),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{
+			"type": {DataType: aws.String("String"), StringValue: aws.String(n.Type)},
+		},
+	})
+	return err
+}
@@ -0,0 +1,126 @@
+// Package projection derives a user's WordStatistics, XP, leaderboard scores
+// and streak from the attempt log.
+//
+// The results handler applies every attempt as it is recorded, and the same
+// folds replay the whole log when a projection has to be rebuilt, e.g. after
+// fixing how the statistics are aggregated.
+//
+// Uploads can arrive out of order, e.g. from a device that was offline. The
+// live folds never move a schedule or streak back in time for an attempt
+// older than the last one applied: it only counts towards the totals. A
+// replay of the log, which is sorted by answeredAt, schedules such an attempt
+// where it belongs, so a rebuild can differ from the live statistics in the
+// review schedule, but not in the counters.
+package projection
+
+import (
+	"sort"
+	"time"
+
+	"hpmaster/internal/srs"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+// Apply folds a single attempt into the statistics of its word.
+func Apply(stat *store.WordStatistics, attempt store.Attempt) error {
+	answeredAt, err := time.Parse(time.RFC3339Nano, attempt.AnsweredAt)
+	if err != nil {
+		return err
+	}
+	answeredAt = answeredAt.UTC()
+	at := answeredAt.Format(time.RFC3339)
+
+	stat.UserId = attempt.UserId
+	stat.Word = attempt.Word
+	stat.Attempts++
+	if attempt.IsCorrect {
+		stat.Success++
+	}
+	stat.SuccessRatio = store.SuccessRatio(stat.Success, stat.Attempts)
+
+	if store.CompareTimestamps(at, stat.LastPracticedAt) >= 0 {
+		interval, nextReview := srs.Next(stat.IntervalDays, attempt.IsCorrect, answeredAt)
+		stat.IntervalDays = interval
+		stat.NextReviewAt = nextReview.Format(time.RFC3339)
+		stat.LastPracticedAt = at
+	}
+	if stat.FirstSeenAt == "" || store.CompareTimestamps(at, stat.FirstSeenAt) < 0 {
+		stat.FirstSeenAt = at
+	}
+	return nil
+}
+
+// Replay rebuilds the statistics per word from a log sorted oldest first.
+func Replay(attempts []store.Attempt) (map[string]*store.WordStatistics, error) {
+	stats := make(map[string]*store.WordStatistics)
+	for _, attempt := range attempts {
+		stat, exists := stats[attempt.Word]
+		if !exists {
+			stat = &store.WordStatistics{}
+			stats[attempt.Word] = stat
+		}
+		if err := Apply(stat, attempt); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// XP is the XP the attempts were awarded.
+func XP(attempts []store.Attempt) int {
+	xp := 0
+	for _, attempt := range attempts {
+		xp += attempt.XP
+	}
+	return xp
+}
+
+// Boards sums the XP of the attempts per leaderboard.
+func Boards(attempts []store.Attempt) map[string]int {
+	scores := make(map[string]int)
+	for _, attempt := range attempts {
+		for _, board := range attempt.Boards {
+			scores[board] += attempt.XP
+		}
+	}
+	return scores
+}
+
+// Practice records the days the attempts were answered on in the streak,
+// oldest first. Days before the last practice day change nothing, see
+// streak.Practice.
+func Practice(state streak.State, attempts []store.Attempt) (streak.Update, error) {
+	var days []time.Time
+	seen := make(map[string]bool)
+	for _, attempt := range attempts {
+		answeredAt, err := time.Parse(time.RFC3339Nano, attempt.AnsweredAt)
+		if err != nil {
+			return streak.Update{}, err
+		}
+		day := streak.Day(answeredAt)
+		if key := day.Format(streak.DateLayout); !seen[key] {
+			seen[key] = true
+			days = append(days, day)
+		}
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	update := streak.Update{State: state}
+	for _, day := range days {
+		next := streak.Practice(update.State, day)
+		update.State = next.State
+		update.FreezesUsed += next.FreezesUsed
+		update.FreezesEarned += next.FreezesEarned
+	}
+	return update, nil
+}
+
+// Streak replays the streak of the whole log within the user's vacation.
+// Only the freezes earned along the way are spent; freezes from gifts or
+// operators are not in the log, so the replayed streak can be shorter than
+// the live one where such a freeze covered a missed day.
+func Streak(attempts []store.Attempt, vacationFrom string, vacationTo string) (streak.State, error) {
+	update, err := Practice(streak.State{VacationFrom: vacationFrom, VacationTo: vacationTo}, attempts)
+	return update.State, err
+}
@@ -0,0 +1,52 @@
+package projection_test
+
+import (
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/projection"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+func attempt(answeredAt string, correct bool) store.Attempt {
+	return store.Attempt{UserId: fixtures.UserId, Word: "värna", IsCorrect: correct, AnsweredAt: answeredAt}
+}
+
+// TestApplyOutOfOrder applies an attempt uploaded after a later one. It
+// counts, but leaves the schedule of the later attempt alone.
+func TestApplyOutOfOrder(t *testing.T) {
+	var stat store.WordStatistics
+	for _, a := range []store.Attempt{
+		attempt("2024-03-15T10:00:00Z", true),
+		attempt("2024-03-14T10:00:00Z", false),
+	} {
+		if err := projection.Apply(&stat, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := store.WordStatistics{
+		UserId: fixtures.UserId, Word: "värna", Attempts: 2, Success: 1, SuccessRatio: 0.5,
+		IntervalDays: 1, NextReviewAt: "2024-03-16T10:00:00Z", LastPracticedAt: "2024-03-15T10:00:00Z",
+		FirstSeenAt: "2024-03-14T10:00:00Z",
+	}
+	if stat != want {
+		t.Errorf("got  %+v\nwant %+v", stat, want)
+	}
+}
+
+func TestPractice(t *testing.T) {
+	state := streak.State{Current: 2, Longest: 2, LastPracticeDate: "2024-03-13"}
+	update, err := projection.Practice(state, []store.Attempt{
+		attempt("2024-03-15T08:00:00Z", true),
+		attempt("2024-03-14T23:00:00Z", true),
+		attempt("2024-03-12T10:00:00Z", true), // before the last practice day
+		attempt("2024-03-15T09:00:00Z", false),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.State.Current != 4 || update.State.LastPracticeDate != "2024-03-15" {
+		t.Errorf("got %+v, want a streak of 4 up to 2024-03-15", update.State)
+	}
+}
@@ -0,0 +1,119 @@
+// Package question turns selected words into questions of the shape a client
+// screen asks for.
+package question
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"hpmaster/internal/store"
+)
+
+// Formats
+const (
+	// MultipleChoice offers the correct answer among wrong ones. The client
+	// grades it and reports isCorrect.
+	MultipleChoice = "multiplechoice"
+	// Spelling asks the user to type the answer, which the server grades.
+	Spelling = "spelling"
+	// TrueFalse asks whether the word means Statement, which is the correct
+	// answer or one of the wrong ones with even odds. The server grades it.
+	TrueFalse = "truefalse"
+)
+
+// Bounds of Options.NumChoices
+const (
+	MinChoices = 2
+	MaxChoices = 10
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown question format")
+	ErrUnsigned      = errors.New("true/false questions need a Signer")
+)
+
+// IsFormat reports whether format is one of the supported formats.
+func IsFormat(format string) bool {
+	switch format {
+	case MultipleChoice, Spelling, TrueFalse:
+		return true
+	}
+	return false
+}
+
+type Question struct {
+	Word     string   `json:"word"`
+	Format   string   `json:"format"`
+	Category string   `json:"category,omitempty"`
+	Options  []string `json:"options,omitempty"`
+	// Statement is the proposed meaning of a true/false question. Token
+	// must be sent back with the answer to it, see Signer.
+	Statement string `json:"statement,omitempty"`
+	Token     string `json:"token,omitempty"`
+	// Correct is only sent for formats the client grades itself.
+	Correct string `json:"correct,omitempty"`
+}
+
+type Options struct {
+	Format string
+	// NumChoices is the number of options of a multiple choice question,
+	// including the correct one. Words with fewer wrong answers get fewer.
+	NumChoices int
+	// Shuffle mixes the options; otherwise the correct answer comes first.
+	Shuffle bool
+	Rand    *rand.Rand
+	// Signer, UserId and Now sign the statements of true/false questions.
+	Signer *Signer
+	UserId string
+	Now    time.Time
+}
+
+// Build returns a question for every word.
+func Build(words []store.Word, opts Options) ([]Question, error) {
+	questions := make([]Question, 0, len(words))
+	for _, word := range words {
+		q := Question{Word: word.Word, Format: opts.Format, Category: word.Category}
+		switch opts.Format {
+		case MultipleChoice:
+			q.Correct = word.Correct
+			q.Options = choices(word, opts)
+		case Spelling:
+		case TrueFalse:
+			if opts.Signer == nil {
+				return nil, ErrUnsigned
+			}
+			q.Statement = statement(word, opts)
+			q.Token = opts.Signer.Sign(opts.UserId, word.Word, q.Statement, opts.Now)
+		default:
+			return nil, ErrUnknownFormat
+		}
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+func choices(word store.Word, opts Options) []string {
+	incorrect := append([]string(nil), word.Incorrect...)
+	if opts.Shuffle {
+		opts.Rand.Shuffle(len(incorrect), func(i, j int) { incorrect[i], incorrect[j] = incorrect[j], incorrect[i] })
+	}
+	if len(incorrect) > opts.NumChoices-1 {
+		incorrect = incorrect[:opts.NumChoices-1]
+	}
+
+	options := append([]string{word.Correct}, incorrect...)
+	if opts.Shuffle {
+		opts.Rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	}
+	return options
+}
+
+// statement proposes the correct answer half of the time and a random wrong
+// one otherwise. Words without wrong answers always get the correct one.
+func statement(word store.Word, opts Options) string {
+	if len(word.Incorrect) == 0 || opts.Rand.Intn(2) == 0 {
+		return word.Correct
+	}
+	return word.Incorrect[opts.Rand.Intn(len(word.Incorrect))]
+}
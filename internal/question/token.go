@@ -0,0 +1,58 @@
+package question
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer issues the tokens of true/false statements. A result for a
+// statement is only graded if its token shows the server served that
+// statement of the word to the user, so clients can't make up statements
+// they know the answer to.
+type Signer struct {
+	secret []byte
+	// ttl is how long after GET /words the results may be uploaded.
+	ttl time.Duration
+}
+
+func NewSigner(secret string, ttl time.Duration) *Signer {
+	return &Signer{secret: []byte(secret), ttl: ttl}
+}
+
+// Sign returns the token of a statement served to the user at issuedAt.
+func (s *Signer) Sign(userId string, word string, statement string, issuedAt time.Time) string {
+	issued := strconv.FormatInt(issuedAt.Unix(), 10)
+	return issued + "." + s.mac(userId, word, statement, issued)
+}
+
+// Verify reports whether token was issued for the statement of the word to
+// the user and hasn't expired.
+func (s *Signer) Verify(token string, userId string, word string, statement string, now time.Time) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	issuedUnix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return false
+	}
+	issuedAt := time.Unix(issuedUnix, 0)
+	if issuedAt.After(now.Add(time.Minute)) || now.Sub(issuedAt) > s.ttl {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(s.mac(userId, word, statement, parts[0])))
+}
+
+func (s *Signer) mac(userId string, word string, statement string, issued string) string {
+	h := hmac.New(sha256.New, s.secret)
+	for _, field := range []string{userId, word, statement, issued} {
+		h.Write([]byte(field))
+		// Separate the fields so they can't be shifted into each other
+		h.Write([]byte{0})
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,55 @@
+package question_test
+
+import (
+	"testing"
+	"time"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/question"
+)
+
+func TestSigner(t *testing.T) {
+	signer := question.NewSigner("secret", time.Hour)
+	token := signer.Sign(fixtures.UserId, "banal", "märklig", fixtures.Now)
+
+	if !signer.Verify(token, fixtures.UserId, "banal", "märklig", fixtures.Now.Add(time.Minute)) {
+		t.Error("the issued token was refused")
+	}
+	refused := map[string]bool{
+		"other user":      signer.Verify(token, "someone-else", "banal", "märklig", fixtures.Now),
+		"other word":      signer.Verify(token, fixtures.UserId, "idog", "märklig", fixtures.Now),
+		"other statement": signer.Verify(token, fixtures.UserId, "banal", "alldaglig", fixtures.Now),
+		"expired":         signer.Verify(token, fixtures.UserId, "banal", "märklig", fixtures.Now.Add(2*time.Hour)),
+		"other secret":    question.NewSigner("other", time.Hour).Verify(token, fixtures.UserId, "banal", "märklig", fixtures.Now),
+		"no token":        signer.Verify("", fixtures.UserId, "banal", "märklig", fixtures.Now),
+	}
+	for name, accepted := range refused {
+		if accepted {
+			t.Errorf("%s: token accepted", name)
+		}
+	}
+}
+
+func TestBuildSignsStatements(t *testing.T) {
+	signer := question.NewSigner("secret", time.Hour)
+	questions, err := question.Build(fixtures.Words(), question.Options{
+		Format: question.TrueFalse,
+		Rand:   fixtures.Rand(1),
+		Signer: signer,
+		UserId: fixtures.UserId,
+		Now:    fixtures.Now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, q := range questions {
+		if !signer.Verify(q.Token, fixtures.UserId, q.Word, q.Statement, fixtures.Now) {
+			t.Errorf("%s: statement %q not signed", q.Word, q.Statement)
+		}
+	}
+
+	_, err = question.Build(fixtures.Words(), question.Options{Format: question.TrueFalse, Rand: fixtures.Rand(1)})
+	if err != question.ErrUnsigned {
+		t.Errorf("got %v without a Signer, want ErrUnsigned", err)
+	}
+}
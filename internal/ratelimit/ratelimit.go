@@ -0,0 +1,148 @@
+// Package ratelimit counts what every user does per day (UTC) and enforces
+// daily limits on it, so a single account can't exhaust the backend.
+//
+// Counters are kept in a CounterStore every lambda container shares, so the
+// limits hold across containers and GET /me/usage sees what the words lambda
+// counted.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"hpmaster/internal/streak"
+)
+
+// Counters
+const (
+	// Requests are authenticated API requests.
+	Requests = "requests"
+	// Sessions are practice sessions started, i.e. word lists fetched.
+	Sessions = "sessions"
+	// Words are practiced words, i.e. uploaded results.
+	Words = "words"
+)
+
+// Counters lists every counter, in display order.
+var Counters = []string{Requests, Sessions, Words}
+
+// Limits maps counters to the most a user may use per period. Counters
+// without a limit, or a limit of 0, are unlimited.
+type Limits map[string]int64
+
+var DefaultLimits = Limits{
+	Requests: 5000,
+	Sessions: 500,
+	Words:    10000,
+}
+
+var ErrLimitExceeded = errors.New("usage limit exceeded")
+
+// LoadLimits parses a JSON object of limits, e.g. {"sessions": 100}, over
+// DefaultLimits. An empty config yields the defaults.
+func LoadLimits(config string) (Limits, error) {
+	limits := make(Limits, len(DefaultLimits))
+	for counter, limit := range DefaultLimits {
+		limits[counter] = limit
+	}
+	if config == "" {
+		return limits, nil
+	}
+	if err := json.Unmarshal([]byte(config), &limits); err != nil {
+		return nil, fmt.Errorf("invalid usage limits: %w", err)
+	}
+	for counter := range limits {
+		if !isCounter(counter) {
+			return nil, fmt.Errorf("invalid usage limits: unknown counter %q", counter)
+		}
+	}
+	return limits, nil
+}
+
+func isCounter(counter string) bool {
+	for _, known := range Counters {
+		if counter == known {
+			return true
+		}
+	}
+	return false
+}
+
+// CounterStore keeps atomic counters per user and period. store.Store keeps
+// them in the Usage table.
+type CounterStore interface {
+	AddUsage(userId string, period string, counter string, n int64, expiresAt time.Time) (int64, error)
+	GetUsage(userId string, period string) (map[string]int64, error)
+}
+
+// retention is how long the counters of a period are kept after it ended.
+const retention = 24 * time.Hour
+
+type Limiter struct {
+	counters CounterStore
+	limits   Limits
+}
+
+func New(counters CounterStore, limits Limits) *Limiter {
+	return &Limiter{counters: counters, limits: limits}
+}
+
+// PeriodStart returns the start of the period now falls in.
+func PeriodStart(now time.Time) time.Time {
+	return streak.Day(now)
+}
+
+// PeriodEnd returns the end of the period now falls in.
+func PeriodEnd(now time.Time) time.Time {
+	return PeriodStart(now).AddDate(0, 0, 1)
+}
+
+// Add counts n uses of the counter by the user. It fails with
+// ErrLimitExceeded if the user went over the limit; the uses are counted
+// anyway, so retrying doesn't help until the period ends.
+func (l *Limiter) Add(userId string, counter string, n int64, now time.Time) error {
+	used, err := l.counters.AddUsage(userId, period(now), counter, n, PeriodEnd(now).Add(retention))
+	if err != nil {
+		return fmt.Errorf("failed to count %s: %w", counter, err)
+	}
+	if limit := l.limits[counter]; limit > 0 && used > limit {
+		return ErrLimitExceeded
+	}
+	return nil
+}
+
+type Counter struct {
+	Used int64 `json:"used"`
+	// Limit is 0 for unlimited counters.
+	Limit int64 `json:"limit"`
+}
+
+type Usage struct {
+	PeriodStart string             `json:"periodStart"`
+	PeriodEnd   string             `json:"periodEnd"`
+	Counters    map[string]Counter `json:"counters"`
+}
+
+// Usage returns the user's counters in the current period.
+func (l *Limiter) Usage(userId string, now time.Time) (*Usage, error) {
+	usage := &Usage{
+		PeriodStart: PeriodStart(now).Format(time.RFC3339),
+		PeriodEnd:   PeriodEnd(now).Format(time.RFC3339),
+		Counters:    make(map[string]Counter, len(Counters)),
+	}
+	used, err := l.counters.GetUsage(userId, period(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	for _, counter := range Counters {
+		usage.Counters[counter] = Counter{Used: used[counter], Limit: l.limits[counter]}
+	}
+	return usage, nil
+}
+
+// period names the period now falls in.
+func period(now time.Time) string {
+	return PeriodStart(now).Format(streak.DateLayout)
+}
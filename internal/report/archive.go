@@ -0,0 +1,140 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+
+	"hpmaster/internal/store"
+)
+
+// Archive keeps the rendered reports of every user in an S3 bucket, under
+// Prefix(userId).
+type Archive struct {
+	client s3iface.S3API
+	bucket string
+}
+
+func NewArchive(client s3iface.S3API, bucket string) *Archive {
+	return &Archive{client: client, bucket: bucket}
+}
+
+// Prefix is the key prefix of the user's reports.
+func Prefix(userId string) string {
+	return "reports/" + userId + "/"
+}
+
+// Put stores a rendered report of the user under name and returns its key.
+func (a *Archive) Put(userId string, name string, contentType string, body []byte) (string, error) {
+	key := Prefix(userId) + name
+	_, err := a.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to put report: %w", err)
+	}
+	return key, nil
+}
+
+// Get returns the body and content type of the report under key.
+func (a *Archive) Get(key string) ([]byte, string, error) {
+	object, err := a.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get report %s: %w", key, err)
+	}
+	defer object.Body.Close()
+	body, err := io.ReadAll(object.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read report %s: %w", key, err)
+	}
+	return body, aws.StringValue(object.ContentType), nil
+}
+
+// Delete deletes the report under key.
+func (a *Archive) Delete(key string) error {
+	_, err := a.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(a.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete report %s: %w", key, err)
+	}
+	return nil
+}
+
+// Export returns every stored report of the user, for store.UserExport.
+func (a *Archive) Export(userId string) ([]store.ExportedReport, error) {
+	var reports []store.ExportedReport
+	err := a.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(a.bucket),
+		Prefix: aws.String(Prefix(userId)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			reports = append(reports, store.ExportedReport{
+				Key:          aws.StringValue(object.Key),
+				LastModified: aws.TimeValue(object.LastModified).Format(time.RFC3339),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+
+	for i := range reports {
+		body, contentType, err := a.Get(reports[i].Key)
+		if err != nil {
+			return nil, err
+		}
+		reports[i].ContentType = contentType
+		reports[i].Body = body
+	}
+	return reports, nil
+}
+
+// DeleteAll deletes every stored report of the user and returns how many
+// were deleted.
+func (a *Archive) DeleteAll(userId string) (int, error) {
+	deleted := 0
+	var deleteErr error
+	err := a.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(a.bucket),
+		Prefix: aws.String(Prefix(userId)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		if len(page.Contents) == 0 {
+			return true
+		}
+		objects := make([]*s3.ObjectIdentifier, 0, len(page.Contents))
+		for _, object := range page.Contents {
+			objects = append(objects, &s3.ObjectIdentifier{Key: object.Key})
+		}
+		var result *s3.DeleteObjectsOutput
+		result, deleteErr = a.client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(a.bucket),
+			Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if deleteErr == nil && len(result.Errors) > 0 {
+			deleteErr = fmt.Errorf("failed to delete %s: %s", aws.StringValue(result.Errors[0].Key), aws.StringValue(result.Errors[0].Message))
+		}
+		if deleteErr != nil {
+			return false
+		}
+		deleted += len(objects)
+		return true
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("failed to list reports: %w", err)
+	}
+	return deleted, deleteErr
+}
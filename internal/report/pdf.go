@@ -0,0 +1,170 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The PDF is a single A4 page, in points from the bottom left corner.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	margin     = 56
+)
+
+// Colors of the HTML page, as RGB fractions
+var (
+	green = [3]float64{0.27, 0.67, 0.47}
+	blue  = [3]float64{0.8, 0.87, 0.93}
+	grey  = [3]float64{0.87, 0.87, 0.87}
+	black = [3]float64{0.13, 0.13, 0.13}
+)
+
+// PDF writes the report as a one page PDF with the content of the HTML page.
+// It only uses the standard Helvetica fonts every PDF reader has, so nothing
+// is embedded; letters outside Latin-1 are shown as "?".
+func (r Report) PDF(w io.Writer) error {
+	var c canvas
+	y := float64(pageHeight - margin - 20)
+	c.text(margin, y, 20, true, r.Name)
+	y -= 22
+	c.text(margin, y, 11, false, "Progress report for "+r.Month)
+
+	y -= 36
+	for _, row := range [][2]string{
+		{"Words practiced", strconv.Itoa(r.WordsPracticed)},
+		{"Words learned", strconv.Itoa(r.WordsLearned)},
+		{"Answers", strconv.Itoa(r.Attempts)},
+		{"Accuracy", strconv.Itoa(percent(r.Accuracy)) + "%"},
+		{"Longest streak", strconv.Itoa(r.LongestStreak) + " days"},
+	} {
+		c.text(margin, y, 11, true, row[0])
+		c.text(margin+140, y, 11, false, row[1])
+		y -= 18
+	}
+
+	y -= 18
+	c.text(margin, y, 14, true, "Accuracy by week")
+	y -= 22
+	for _, week := range r.Weeks {
+		accuracy := "-"
+		if week.Attempts > 0 {
+			accuracy = strconv.Itoa(percent(week.Accuracy)) + "%"
+		}
+		c.text(margin, y, 11, true, week.Start)
+		c.text(margin+100, y, 11, false, accuracy)
+		c.rect(margin+160, y-1, 200*float64(week.Accuracy), 10, green, true)
+		y -= 18
+	}
+
+	y -= 18
+	c.text(margin, y, 14, true, "Practice calendar")
+	y -= 8
+	// 7 days a row, starting on the 1st
+	const cell = 24
+	for i, day := range r.Calendar {
+		x := float64(margin + i%7*cell)
+		top := y - float64(i/7*cell)
+		switch {
+		case day.Practiced:
+			c.rect(x, top-cell, cell, cell, green, true)
+		case day.Vacation:
+			c.rect(x, top-cell, cell, cell, blue, true)
+		}
+		c.rect(x, top-cell, cell, cell, grey, false)
+		label := strconv.Itoa(i + 1)
+		// Helvetica digits are 0.556 em wide
+		c.text(x+(cell-0.556*9*float64(len(label)))/2, top-cell+8, 9, false, label)
+	}
+
+	c.text(margin, margin, 8, false, "Generated "+r.GeneratedAt)
+	return writePDF(w, c.Bytes())
+}
+
+func percent(ratio float32) int {
+	return int(ratio*100 + 0.5)
+}
+
+// canvas collects the drawing operators of the page's content stream.
+type canvas struct {
+	bytes.Buffer
+}
+
+// text draws s with its baseline starting at x, y, in Helvetica (F1) or
+// Helvetica-Bold (F2).
+func (c *canvas) text(x float64, y float64, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(c, "%s rg BT /%s %s Tf %s %s Td (%s) Tj ET\n", rgb(black), font, num(size), num(x), num(y), escape(s))
+}
+
+// rect fills or strokes a rectangle with its bottom left corner at x, y.
+func (c *canvas) rect(x float64, y float64, width float64, height float64, color [3]float64, fill bool) {
+	if fill {
+		fmt.Fprintf(c, "%s rg %s %s %s %s re f\n", rgb(color), num(x), num(y), num(width), num(height))
+		return
+	}
+	fmt.Fprintf(c, "%s RG %s %s %s %s re S\n", rgb(color), num(x), num(y), num(width), num(height))
+}
+
+func rgb(color [3]float64) string {
+	return num(color[0]) + " " + num(color[1]) + " " + num(color[2])
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// escape encodes s as the body of a PDF string in WinAnsiEncoding, which
+// matches Latin-1 from 0xA0 on.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '–':
+			b.WriteByte(0x96)
+		case r >= 0x20 && r < 0x7f || r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// writePDF writes a document of one page with the given content stream.
+func writePDF(w io.Writer, content []byte) error {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	_, err := w.Write(doc.Bytes())
+	return err
+}
@@ -0,0 +1,47 @@
+package report_test
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/report"
+	"hpmaster/internal/store"
+)
+
+var xrefEntry = regexp.MustCompile(`(\d{10}) 00000 n `)
+
+// TestPDF checks that the cross-reference table points at the objects, which
+// readers rely on, and that text is escaped and Latin-1 encoded.
+func TestPDF(t *testing.T) {
+	user := store.User{UserId: fixtures.UserId, Name: "Åsa (7B)"}
+	attempts := []store.Attempt{
+		{Word: "värna", IsCorrect: true, AnsweredAt: "2024-03-04T10:00:00Z"},
+		{Word: "banal", IsCorrect: false, AnsweredAt: "2024-03-05T10:00:00Z"},
+	}
+	var pdf bytes.Buffer
+	if err := report.Build(user, fixtures.Now, attempts, fixtures.Now).PDF(&pdf); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := pdf.Bytes()
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Fatalf("not a PDF:\n%s", doc)
+	}
+	offsets := xrefEntry.FindAllSubmatch(doc, -1)
+	if len(offsets) != 6 {
+		t.Fatalf("%d objects in the xref table, want 6", len(offsets))
+	}
+	for i, offset := range offsets {
+		at, _ := strconv.Atoi(string(offset[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(doc[at:], []byte(want)) {
+			t.Errorf("xref entry %d points at %q", i+1, doc[at:at+10])
+		}
+	}
+	if !bytes.Contains(doc, []byte("(\xc5sa \\(7B\\)) Tj")) {
+		t.Error("name is not escaped and Latin-1 encoded")
+	}
+}
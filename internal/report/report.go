@@ -0,0 +1,186 @@
+// Package report builds the monthly progress report a user can share, e.g.
+// with a teacher, and renders it as a self-contained HTML page or as a PDF.
+//
+// Reports are computed from the attempt log, so they show what happened in
+// the month even if the statistics changed since.
+package report
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+// MonthLayout is the format of the month a report covers.
+const MonthLayout = "2006-01"
+
+type Report struct {
+	Name  string `json:"name"`
+	Month string `json:"month"`
+	// WordsPracticed are the distinct words answered in the month.
+	WordsPracticed int `json:"wordsPracticed"`
+	// WordsLearned are the words first answered correctly in the month.
+	WordsLearned int     `json:"wordsLearned"`
+	Attempts     int     `json:"attempts"`
+	Correct      int     `json:"correct"`
+	Accuracy     float32 `json:"accuracy"`
+	// Weeks is the accuracy trend, in 7 day periods from the 1st.
+	Weeks []Period `json:"weeks"`
+	// Calendar has an entry for every day of the month.
+	Calendar []Day `json:"calendar"`
+	// LongestStreak is the longest run of practiced days in the month.
+	LongestStreak int    `json:"longestStreak"`
+	GeneratedAt   string `json:"generatedAt"`
+}
+
+type Period struct {
+	Start    string  `json:"start"`
+	Attempts int     `json:"attempts"`
+	Correct  int     `json:"correct"`
+	Accuracy float32 `json:"accuracy"`
+}
+
+type Day struct {
+	Date      string `json:"date"`
+	Attempts  int    `json:"attempts"`
+	Practiced bool   `json:"practiced"`
+	Vacation  bool   `json:"vacation,omitempty"`
+}
+
+// ParseMonth parses a month in MonthLayout, e.g. "2024-03".
+func ParseMonth(month string) (time.Time, error) {
+	return time.Parse(MonthLayout, month)
+}
+
+// Build computes the report of the month starting at month from all of the
+// user's attempts.
+func Build(user store.User, month time.Time, attempts []store.Attempt, now time.Time) Report {
+	start := streak.Day(month)
+	start = start.AddDate(0, 0, 1-start.Day())
+	end := start.AddDate(0, 1, 0)
+	from := start.Format(streak.DateLayout)
+	to := end.Format(streak.DateLayout)
+
+	report := Report{
+		Name:        user.Name,
+		Month:       start.Format(MonthLayout),
+		GeneratedAt: now.Format(time.RFC3339),
+	}
+	days := make(map[string]*Day)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		report.Calendar = append(report.Calendar, Day{
+			Date:     day.Format(streak.DateLayout),
+			Vacation: user.State.OnVacation(day),
+		})
+	}
+	for i := range report.Calendar {
+		days[report.Calendar[i].Date] = &report.Calendar[i]
+	}
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 7) {
+		report.Weeks = append(report.Weeks, Period{Start: day.Format(streak.DateLayout)})
+	}
+
+	practiced := make(map[string]bool)
+	// firstCorrect is the day each word was first answered correctly
+	firstCorrect := make(map[string]string)
+	for _, attempt := range attempts {
+		answeredAt, err := time.Parse(time.RFC3339, attempt.AnsweredAt)
+		if err != nil {
+			continue
+		}
+		date := streak.Day(answeredAt).Format(streak.DateLayout)
+		if attempt.IsCorrect {
+			if first, exists := firstCorrect[attempt.Word]; !exists || date < first {
+				firstCorrect[attempt.Word] = date
+			}
+		}
+		if date < from || date >= to {
+			continue
+		}
+
+		practiced[attempt.Word] = true
+		report.Attempts++
+		days[date].Attempts++
+		days[date].Practiced = true
+		week := &report.Weeks[(answeredAt.UTC().Day()-1)/7]
+		week.Attempts++
+		if attempt.IsCorrect {
+			report.Correct++
+			week.Correct++
+		}
+	}
+
+	report.WordsPracticed = len(practiced)
+	for _, date := range firstCorrect {
+		if date >= from && date < to {
+			report.WordsLearned++
+		}
+	}
+	report.Accuracy = store.SuccessRatio(report.Correct, report.Attempts)
+	for i := range report.Weeks {
+		report.Weeks[i].Accuracy = store.SuccessRatio(report.Weeks[i].Correct, report.Weeks[i].Attempts)
+	}
+	run := 0
+	for _, day := range report.Calendar {
+		if !day.Practiced {
+			run = 0
+			continue
+		}
+		run++
+		if run > report.LongestStreak {
+			report.LongestStreak = run
+		}
+	}
+	return report
+}
+
+// HTML writes the report as a standalone HTML page.
+func (r Report) HTML(w io.Writer) error {
+	return page.Execute(w, r)
+}
+
+var page = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": percent,
+	// The calendar shows 7 days a row, starting on the 1st
+	"newRow":     func(i int) bool { return i > 0 && i%7 == 0 },
+	"dayOfMonth": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} – progress {{.Month}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; }
+td, th { padding: .3em .6em; text-align: left; }
+.bar { background: #4a7; height: 1em; }
+.calendar td { width: 1.6em; height: 1.6em; text-align: center; border: 1px solid #ddd; }
+.practiced { background: #4a7; color: #fff; }
+.vacation { background: #cde; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>Progress report for {{.Month}}</p>
+<table>
+<tr><th>Words practiced</th><td>{{.WordsPracticed}}</td></tr>
+<tr><th>Words learned</th><td>{{.WordsLearned}}</td></tr>
+<tr><th>Answers</th><td>{{.Attempts}}</td></tr>
+<tr><th>Accuracy</th><td>{{percent .Accuracy}}%</td></tr>
+<tr><th>Longest streak</th><td>{{.LongestStreak}} days</td></tr>
+</table>
+<h2>Accuracy by week</h2>
+<table>
+{{range .Weeks}}<tr><th>{{.Start}}</th><td>{{if .Attempts}}{{percent .Accuracy}}%{{else}}–{{end}}</td><td style="width: 15em"><div class="bar" style="width: {{percent .Accuracy}}%"></div></td></tr>
+{{end}}</table>
+<h2>Practice calendar</h2>
+<table class="calendar"><tr>
+{{range $i, $day := .Calendar}}{{if newRow $i}}</tr><tr>{{end}}<td class="{{if $day.Practiced}}practiced{{else if $day.Vacation}}vacation{{end}}" title="{{$day.Date}}: {{$day.Attempts}} answers">{{dayOfMonth $i}}</td>{{end}}
+</tr></table>
+<p><small>Generated {{.GeneratedAt}}</small></p>
+</body>
+</html>
+`))
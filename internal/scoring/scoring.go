@@ -0,0 +1,104 @@
+// Package scoring computes the XP awarded for answered words.
+//
+// The numbers live in a Table so they can be tuned through configuration
+// (the SCORING_TABLE environment variable of the words lambda) without a
+// code change.
+package scoring
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+type Table struct {
+	// BasePoints is awarded for a correct answer before any multiplier.
+	BasePoints float64 `json:"basePoints"`
+	// DifficultyMultipliers is keyed by Word.Difficulty. Words without a
+	// known difficulty use DefaultDifficultyMultiplier.
+	DifficultyMultipliers       map[string]float64 `json:"difficultyMultipliers"`
+	DefaultDifficultyMultiplier float64            `json:"defaultDifficultyMultiplier"`
+	// Answers faster than FastAnswerMs are multiplied by FastAnswerMultiplier.
+	FastAnswerMs         int     `json:"fastAnswerMs"`
+	FastAnswerMultiplier float64 `json:"fastAnswerMultiplier"`
+	// HintMultiplier applies when a hint was used.
+	HintMultiplier float64 `json:"hintMultiplier"`
+	// RetryMultiplier applies once per retry before the correct answer.
+	RetryMultiplier float64 `json:"retryMultiplier"`
+	// MinPoints is the floor for a correct answer after all handicaps.
+	MinPoints int `json:"minPoints"`
+}
+
+type Answer struct {
+	Difficulty      string
+	IsCorrect       bool
+	ResponseTimeMs  int
+	HintUsed        bool
+	Retries         int
+	EventMultiplier float64 // Bonus of an active event, 0 means none
+}
+
+var DefaultTable = Table{
+	BasePoints: 10,
+	DifficultyMultipliers: map[string]float64{
+		"easy":   1,
+		"medium": 1.5,
+		"hard":   2,
+	},
+	DefaultDifficultyMultiplier: 1,
+	FastAnswerMs:                5000,
+	FastAnswerMultiplier:        1.5,
+	HintMultiplier:              0.5,
+	RetryMultiplier:             0.5,
+	MinPoints:                   1,
+}
+
+// LoadTable parses a scoring table from JSON. Fields missing from the JSON
+// keep their DefaultTable values; an empty string yields DefaultTable.
+func LoadTable(config string) (Table, error) {
+	table := DefaultTable
+	if config == "" {
+		return table, nil
+	}
+	// Unmarshal merges into an existing map, so don't let it touch DefaultTable's.
+	table.DifficultyMultipliers = make(map[string]float64, len(DefaultTable.DifficultyMultipliers))
+	for k, v := range DefaultTable.DifficultyMultipliers {
+		table.DifficultyMultipliers[k] = v
+	}
+	if err := json.Unmarshal([]byte(config), &table); err != nil {
+		return Table{}, fmt.Errorf("invalid scoring table: %w", err)
+	}
+	return table, nil
+}
+
+// Points returns the XP awarded for an answer. Incorrect answers award nothing.
+func (t Table) Points(answer Answer) int {
+	if !answer.IsCorrect {
+		return 0
+	}
+
+	points := t.BasePoints
+	if m, ok := t.DifficultyMultipliers[answer.Difficulty]; ok {
+		points *= m
+	} else {
+		points *= t.DefaultDifficultyMultiplier
+	}
+	if answer.ResponseTimeMs > 0 && answer.ResponseTimeMs < t.FastAnswerMs {
+		points *= t.FastAnswerMultiplier
+	}
+	if answer.HintUsed {
+		points *= t.HintMultiplier
+	}
+	if answer.Retries > 0 {
+		points *= math.Pow(t.RetryMultiplier, float64(answer.Retries))
+	}
+	if answer.EventMultiplier > 0 {
+		points *= answer.EventMultiplier
+	}
+
+	result := int(math.Round(points))
+	if result < t.MinPoints {
+		result = t.MinPoints
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package scoring_test
+
+import (
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/scoring"
+)
+
+// answers are the kinds of answer scored for every fixture word.
+var answers = map[string]scoring.Answer{
+	"incorrect": {IsCorrect: false, ResponseTimeMs: 2000},
+	"plain":     {IsCorrect: true},
+	"fast":      {IsCorrect: true, ResponseTimeMs: 2000},
+	"slow":      {IsCorrect: true, ResponseTimeMs: 12000},
+	"hint":      {IsCorrect: true, HintUsed: true},
+	"retries":   {IsCorrect: true, Retries: 2},
+	"handicaps": {IsCorrect: true, HintUsed: true, Retries: 3},
+	"event":     {IsCorrect: true, ResponseTimeMs: 2000, EventMultiplier: 2},
+}
+
+func TestPoints(t *testing.T) {
+	points := make(map[string]map[string]int)
+	for _, word := range fixtures.Words() {
+		points[word.Word] = make(map[string]int)
+		for name, answer := range answers {
+			answer.Difficulty = word.Difficulty
+			points[word.Word][name] = scoring.DefaultTable.Points(answer)
+		}
+	}
+	fixtures.Golden(t, "points", points)
+}
+
+func TestPointsWithManyRetries(t *testing.T) {
+	answer := scoring.Answer{Difficulty: "hard", IsCorrect: true, Retries: 2000000000}
+	if got := scoring.DefaultTable.Points(answer); got != scoring.DefaultTable.MinPoints {
+		t.Errorf("got %d, want MinPoints %d", got, scoring.DefaultTable.MinPoints)
+	}
+}
+
+func TestLoadTable(t *testing.T) {
+	table, err := scoring.LoadTable(`{"basePoints": 20, "difficultyMultipliers": {"hard": 3}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.BasePoints != 20 || table.DifficultyMultipliers["hard"] != 3 || table.DifficultyMultipliers["easy"] != 1 {
+		t.Errorf("config not merged over the defaults: %+v", table)
+	}
+	if scoring.DefaultTable.DifficultyMultipliers["hard"] != 2 {
+		t.Error("LoadTable modified DefaultTable")
+	}
+}
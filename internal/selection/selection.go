@@ -0,0 +1,103 @@
+// Package selection decides which words a user practices next.
+//
+// A Selector orders the candidate words of a request; Select then takes the
+// first Limit of them while respecting the daily allowance of new words.
+// Strategies register under a name so the words lambda can pick one from its
+// SELECTION_STRATEGY configuration or the strategy query parameter.
+package selection
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	"hpmaster/internal/store"
+)
+
+type Request struct {
+	UserId string
+	Limit  int
+	// Candidates are the words the user may practice, e.g. those of the
+	// installed packs.
+	Candidates []store.Word
+	// Stats are the user's WordStatistics, keyed by word.
+	Stats map[string]store.WordStatistics
+	// NewWordBudget is how many never practiced words may be introduced.
+	NewWordBudget int
+	Now           time.Time
+	// Params are the query parameters of the request, for strategies that
+	// take options.
+	Params map[string]string
+	Rand   *rand.Rand
+}
+
+// IsNew reports whether the user never practiced the word.
+func (r *Request) IsNew(word string) bool {
+	_, practiced := r.Stats[word]
+	return !practiced
+}
+
+type Selector interface {
+	// Order returns the candidates in the order they should be practiced.
+	// It may leave candidates out.
+	Order(req *Request) []store.Word
+}
+
+// SelectorFunc adapts a function to the Selector interface.
+type SelectorFunc func(req *Request) []store.Word
+
+func (f SelectorFunc) Order(req *Request) []store.Word {
+	return f(req)
+}
+
+var selectors = map[string]Selector{}
+
+// Register makes a selector available under name, replacing any selector
+// registered under it before.
+func Register(name string, selector Selector) {
+	selectors[name] = selector
+}
+
+// Get returns the selector registered under name.
+func Get(name string) (Selector, bool) {
+	selector, exists := selectors[name]
+	return selector, exists
+}
+
+// Names returns the registered strategy names, sorted.
+func Names() []string {
+	names := make([]string, 0, len(selectors))
+	for name := range selectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select returns up to req.Limit words in the selector's order, skipping new
+// words once the new word budget is spent.
+func Select(selector Selector, req *Request) []store.Word {
+	if req.Rand == nil {
+		req.Rand = rand.New(rand.NewSource(req.Now.UnixNano()))
+	}
+	budget := req.NewWordBudget
+	seen := make(map[string]bool, req.Limit)
+	words := make([]store.Word, 0, req.Limit)
+	for _, word := range selector.Order(req) {
+		if len(words) == req.Limit {
+			break
+		}
+		if seen[word.Word] {
+			continue
+		}
+		if req.IsNew(word.Word) {
+			if budget <= 0 {
+				continue
+			}
+			budget--
+		}
+		seen[word.Word] = true
+		words = append(words, word)
+	}
+	return words
+}
@@ -0,0 +1,71 @@
+package selection_test
+
+import (
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/selection"
+)
+
+func request(limit int, newWordBudget int, seed int64) *selection.Request {
+	return &selection.Request{
+		UserId:        fixtures.UserId,
+		Limit:         limit,
+		Candidates:    fixtures.Words(),
+		Stats:         fixtures.WordStatistics(),
+		NewWordBudget: newWordBudget,
+		Now:           fixtures.Now,
+		Rand:          fixtures.Rand(seed),
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	picks := make(map[string][]string)
+	for _, name := range selection.Names() {
+		selector, _ := selection.Get(name)
+		for _, word := range selection.Select(selector, request(6, 2, 1)) {
+			picks[name] = append(picks[name], word.Word)
+		}
+	}
+	fixtures.Golden(t, "strategies", picks)
+}
+
+func TestSelectIsDeterministic(t *testing.T) {
+	for _, name := range selection.Names() {
+		selector, _ := selection.Get(name)
+		first := selection.Select(selector, request(8, 3, 7))
+		second := selection.Select(selector, request(8, 3, 7))
+		if len(first) != len(second) {
+			t.Fatalf("%s: got %d words, then %d", name, len(first), len(second))
+		}
+		for i := range first {
+			if first[i].Word != second[i].Word {
+				t.Errorf("%s: word %d is %s, then %s", name, i, first[i].Word, second[i].Word)
+			}
+		}
+	}
+}
+
+func TestSelectRespectsNewWordBudget(t *testing.T) {
+	for _, name := range selection.Names() {
+		selector, _ := selection.Get(name)
+		for budget := 0; budget <= 3; budget++ {
+			req := request(len(fixtures.Words()), budget, 3)
+			words := selection.Select(selector, req)
+			introduced := 0
+			seen := make(map[string]bool)
+			for _, word := range words {
+				if seen[word.Word] {
+					t.Errorf("%s: %s selected twice", name, word.Word)
+				}
+				seen[word.Word] = true
+				if req.IsNew(word.Word) {
+					introduced++
+				}
+			}
+			if introduced > budget {
+				t.Errorf("%s: introduced %d new words with a budget of %d", name, introduced, budget)
+			}
+		}
+	}
+}
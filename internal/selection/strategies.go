@@ -0,0 +1,118 @@
+package selection
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"hpmaster/internal/store"
+)
+
+// Built-in strategies
+const (
+	Random   = "random"
+	Weakness = "weakness"
+	Spaced   = "spaced"
+	Balanced = "balanced"
+)
+
+// minWeight keeps well known words in the weakness rotation.
+const minWeight = 0.05
+
+func init() {
+	Register(Random, SelectorFunc(orderRandom))
+	Register(Weakness, SelectorFunc(orderByWeakness))
+	Register(Spaced, SelectorFunc(orderBySchedule))
+	Register(Balanced, SelectorFunc(orderBalanced))
+}
+
+// orderRandom shuffles the candidates.
+func orderRandom(req *Request) []store.Word {
+	words := append([]store.Word(nil), req.Candidates...)
+	req.Rand.Shuffle(len(words), func(i, j int) { words[i], words[j] = words[j], words[i] })
+	return words
+}
+
+// orderByWeakness samples the candidates weighted by their failure ratio, so
+// weak words come up often without crowding out everything else. New words
+// weigh as much as a word answered right half of the time.
+func orderByWeakness(req *Request) []store.Word {
+	type keyed struct {
+		word store.Word
+		key  float64
+	}
+	keys := make([]keyed, 0, len(req.Candidates))
+	for _, word := range req.Candidates {
+		weight := 0.5
+		if stat, exists := req.Stats[word.Word]; exists {
+			weight = 1 - float64(stat.SuccessRatio)
+		}
+		if weight < minWeight {
+			weight = minWeight
+		}
+		// Weighted sampling without replacement (Efraimidis-Spirakis)
+		keys = append(keys, keyed{word: word, key: math.Pow(req.Rand.Float64(), 1/weight)})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key > keys[j].key })
+
+	words := make([]store.Word, 0, len(keys))
+	for _, k := range keys {
+		words = append(words, k.word)
+	}
+	return words
+}
+
+// orderBySchedule puts due reviews first, most overdue first, then new words,
+// then the words reviewed soonest. Practiced words without a schedule are
+// treated as overdue.
+func orderBySchedule(req *Request) []store.Word {
+	now := req.Now.UTC().Format(time.RFC3339)
+	var due, fresh, later []store.Word
+	for _, word := range orderRandom(req) {
+		stat, exists := req.Stats[word.Word]
+		switch {
+		case !exists:
+			fresh = append(fresh, word)
+		case store.CompareTimestamps(stat.NextReviewAt, now) <= 0:
+			due = append(due, word)
+		default:
+			later = append(later, word)
+		}
+	}
+	byReview := func(words []store.Word) {
+		sort.SliceStable(words, func(i, j int) bool {
+			return store.CompareTimestamps(req.Stats[words[i].Word].NextReviewAt, req.Stats[words[j].Word].NextReviewAt) < 0
+		})
+	}
+	byReview(due)
+	byReview(later)
+
+	words := make([]store.Word, 0, len(req.Candidates))
+	words = append(words, due...)
+	words = append(words, fresh...)
+	return append(words, later...)
+}
+
+// orderBalanced takes the categories in turn, so a session covers as many
+// categories as possible. Words without a category form a group of their own.
+func orderBalanced(req *Request) []store.Word {
+	var categories []string
+	byCategory := make(map[string][]store.Word)
+	for _, word := range orderRandom(req) {
+		if _, exists := byCategory[word.Category]; !exists {
+			categories = append(categories, word.Category)
+		}
+		byCategory[word.Category] = append(byCategory[word.Category], word)
+	}
+
+	words := make([]store.Word, 0, len(req.Candidates))
+	for len(words) < len(req.Candidates) {
+		for _, category := range categories {
+			if group := byCategory[category]; len(group) > 0 {
+				words = append(words, group[0])
+				byCategory[category] = group[1:]
+			}
+		}
+	}
+	return words
+}
@@ -0,0 +1,26 @@
+// Package srs schedules word reviews (spaced repetition).
+//
+// Every correct answer doubles the review interval of a word, a wrong answer
+// resets it so the word is due again right away.
+package srs
+
+import "time"
+
+// MaxIntervalDays caps how far into the future a review is scheduled.
+const MaxIntervalDays = 180
+
+// Next returns the new interval in days and the time of the next review after
+// answering a word whose current interval is intervalDays.
+func Next(intervalDays int, correct bool, now time.Time) (int, time.Time) {
+	if !correct {
+		return 0, now
+	}
+	interval := intervalDays * 2
+	if interval < 1 {
+		interval = 1
+	}
+	if interval > MaxIntervalDays {
+		interval = MaxIntervalDays
+	}
+	return interval, now.AddDate(0, 0, interval)
+}
@@ -0,0 +1,359 @@
+package store
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+const (
+	AttemptsTableName = "Attempts"
+
+	// BatchWriteItem accepts at most 25 requests
+	maxBatchWrite = 25
+	// maxRecordBatch is how many attempts RecordAttempts puts in one
+	// transaction, leaving room for the statistics, user and leaderboard
+	// updates within DynamoDB's limit of 100 items.
+	maxRecordBatch = 50
+	maxRecordTries = 5
+)
+
+// clientAttemptIdPattern is what a client may use as attemptId, e.g. a UUID.
+var clientAttemptIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Attempt is a single answered word. The attempt log is the source of truth
+// for WordStatistics, XP, leaderboard scores and streaks, which are
+// projections of it (see package projection). Attempts are never updated.
+type Attempt struct {
+	UserId string `json:"userId"`
+	// AttemptId starts with AnsweredAt so the log sorts chronologically.
+	AttemptId      string `json:"attemptId"`
+	Word           string `json:"word"`
+	IsCorrect      bool   `json:"isCorrect"`
+	Answer         string `json:"answer,omitempty"`
+	Statement      string `json:"statement,omitempty"`
+	ResponseTimeMs int    `json:"responseTimeMs,omitempty"`
+	HintUsed       bool   `json:"hintUsed,omitempty"`
+	Retries        int    `json:"retries,omitempty"`
+	// XP is what the attempt was awarded, with the scoring table in use at
+	// the time and including an XP boost.
+	XP int `json:"xp"`
+	// Boards are the leaderboards the XP counted on. Attempts logged before
+	// boards were recorded have none.
+	Boards     []string `json:"boards,omitempty"`
+	DeviceId   string   `json:"deviceId,omitempty"`
+	AnsweredAt string   `json:"answeredAt"`
+}
+
+// NewAttemptId returns a unique attemptId for an answer given at answeredAt.
+func NewAttemptId(answeredAt time.Time) string {
+	return ClientAttemptId(answeredAt, uuid.New().String())
+}
+
+// ClientAttemptId returns the attemptId of an answer the client identified
+// with clientId. A retried upload yields the same attemptId, so
+// RecordAttempts can tell the attempt is already logged.
+func ClientAttemptId(answeredAt time.Time, clientId string) string {
+	return answeredAt.UTC().Format(idTimeLayout) + "#" + clientId
+}
+
+// IsValidClientAttemptId reports whether a client supplied attemptId can be
+// used with ClientAttemptId.
+func IsValidClientAttemptId(clientId string) bool {
+	return clientAttemptIdPattern.MatchString(clientId)
+}
+
+// LoggedAttemptIds returns which of the user's attemptIds are in the log
+// already, e.g. because an upload is retried.
+func (s *Store) LoggedAttemptIds(userId string, attemptIds []string) (map[string]bool, error) {
+	logged := make(map[string]bool)
+	for start := 0; start < len(attemptIds); start += maxBatchGet {
+		end := start + maxBatchGet
+		if end > len(attemptIds) {
+			end = len(attemptIds)
+		}
+		keys := make([]map[string]*dynamodb.AttributeValue, 0, end-start)
+		for _, attemptId := range attemptIds[start:end] {
+			keys = append(keys, attemptKey(userId, attemptId))
+		}
+		pending := map[string]*dynamodb.KeysAndAttributes{
+			AttemptsTableName: {Keys: keys, ProjectionExpression: aws.String("attemptId")},
+		}
+		for try := 0; len(pending) > 0; try++ {
+			if try > 0 {
+				time.Sleep(time.Duration(try*try) * 50 * time.Millisecond)
+			}
+			if try == 5 {
+				return nil, fmt.Errorf("%d attempts left unprocessed", len(pending[AttemptsTableName].Keys))
+			}
+			result, err := s.db.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get attempts: %w", err)
+			}
+			for _, item := range result.Responses[AttemptsTableName] {
+				logged[aws.StringValue(item["attemptId"].S)] = true
+			}
+			pending = result.UnprocessedKeys
+		}
+	}
+	return logged, nil
+}
+
+// RecordAttempts adds the user's attempts to the log and applies them to
+// their projections: apply folds them into the statistics of their word,
+// their XP is added to the user and to each of their Boards. An attempt is
+// logged and projected in the same transaction, so neither happens without
+// the other. Attempts already in the log are left out, which makes retrying
+// an upload safe. The attempts of a word are applied oldest first; the
+// recorded ones are returned in that order, grouped by word.
+func (s *Store) RecordAttempts(user *User, attempts []Attempt, apply func(stat *WordStatistics, attempt Attempt) error) ([]Attempt, error) {
+	var words []string
+	byWord := make(map[string][]Attempt)
+	for _, attempt := range attempts {
+		if _, exists := byWord[attempt.Word]; !exists {
+			words = append(words, attempt.Word)
+		}
+		byWord[attempt.Word] = append(byWord[attempt.Word], attempt)
+	}
+
+	var recorded []Attempt
+	for _, word := range words {
+		batch := byWord[word]
+		sort.Slice(batch, func(i, j int) bool { return batch[i].AttemptId < batch[j].AttemptId })
+		for start := 0; start < len(batch); start += maxRecordBatch {
+			end := start + maxRecordBatch
+			if end > len(batch) {
+				end = len(batch)
+			}
+			added, err := s.recordWordAttempts(user, word, batch[start:end], apply)
+			if err != nil {
+				return recorded, fmt.Errorf("failed to record attempts of %s: %w", word, err)
+			}
+			recorded = append(recorded, added...)
+		}
+	}
+	return recorded, nil
+}
+
+// recordWordAttempts records attempts of a single word in one transaction.
+// When the transaction is canceled, the attempts found in the log are
+// dropped and the rest retried on freshly read statistics.
+func (s *Store) recordWordAttempts(user *User, word string, attempts []Attempt, apply func(stat *WordStatistics, attempt Attempt) error) ([]Attempt, error) {
+	pending := attempts
+	for try := 0; len(pending) > 0; try++ {
+		if try == maxRecordTries {
+			return nil, fmt.Errorf("statistics kept changing")
+		}
+		stat, err := s.getWordStatistics(user.UserId, word)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get word statistics: %w", err)
+		}
+		if stat == nil {
+			stat = &WordStatistics{UserId: user.UserId, Word: word}
+		}
+		prevAttempts := stat.Attempts
+
+		writes := make([]*dynamodb.TransactWriteItem, 0, len(pending)+2)
+		xp := 0
+		boardXP := make(map[string]int)
+		for _, attempt := range pending {
+			if err := apply(stat, attempt); err != nil {
+				return nil, err
+			}
+			item, err := dynamodbattribute.MarshalMap(attempt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal attempt: %w", err)
+			}
+			writes = append(writes, &dynamodb.TransactWriteItem{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(AttemptsTableName),
+					Item:                item,
+					ConditionExpression: aws.String("attribute_not_exists(attemptId)"),
+				},
+			})
+			xp += attempt.XP
+			for _, board := range attempt.Boards {
+				boardXP[board] += attempt.XP
+			}
+		}
+
+		item, err := dynamodbattribute.MarshalMap(stat)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal word statistics: %w", err)
+		}
+		writes = append(writes, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				TableName:           aws.String(WordStatsTableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(word) OR attempts = :prevAttempts"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":prevAttempts": {N: aws.String(strconv.Itoa(prevAttempts))},
+				},
+			},
+		})
+		if xp != 0 {
+			writes = append(writes, &dynamodb.TransactWriteItem{
+				Update: &dynamodb.Update{
+					TableName: aws.String(UsersTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"userId": {S: aws.String(user.UserId)},
+					},
+					UpdateExpression: aws.String("ADD xp :xp"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":xp": {N: aws.String(strconv.Itoa(xp))},
+					},
+				},
+			})
+		}
+		for board, score := range boardXP {
+			if score != 0 {
+				writes = append(writes, &dynamodb.TransactWriteItem{
+					Update: leaderboardScoreUpdate(board, user.UserId, user.Name, score),
+				})
+			}
+		}
+
+		_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: writes})
+		if err == nil {
+			return pending, nil
+		}
+		canceled, ok := err.(*dynamodb.TransactionCanceledException)
+		if !ok {
+			return nil, err
+		}
+		// The first len(pending) items are the attempts
+		var fresh []Attempt
+		for i, attempt := range pending {
+			if i < len(canceled.CancellationReasons) {
+				if code := canceled.CancellationReasons[i].Code; code != nil && *code == "ConditionalCheckFailed" {
+					continue
+				}
+			}
+			fresh = append(fresh, attempt)
+		}
+		if len(fresh) == len(pending) {
+			// The statistics changed or another transaction got in the way
+			time.Sleep(time.Duration(try+1) * 50 * time.Millisecond)
+		}
+		pending = fresh
+	}
+	return nil, nil
+}
+
+func attemptKey(userId string, attemptId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId":    {S: aws.String(userId)},
+		"attemptId": {S: aws.String(attemptId)},
+	}
+}
+
+// batchWrite writes requests to the table, retrying the items DynamoDB did
+// not process.
+func (s *Store) batchWrite(tableName string, requests []*dynamodb.WriteRequest) error {
+	pending := map[string][]*dynamodb.WriteRequest{tableName: requests}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * 50 * time.Millisecond)
+		}
+		if attempt == 5 {
+			return fmt.Errorf("%d items left unprocessed", len(pending[tableName]))
+		}
+		result, err := s.db.BatchWriteItem(&dynamodb.BatchWriteItemInput{RequestItems: pending})
+		if err != nil {
+			return err
+		}
+		pending = result.UnprocessedItems
+	}
+	return nil
+}
+
+// ListAttempts returns the user's attempt log, oldest first.
+func (s *Store) ListAttempts(userId string) ([]Attempt, error) {
+	var attempts []Attempt
+	var unmarshalErr error
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(AttemptsTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}
+
+	err := s.db.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []Attempt
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		attempts = append(attempts, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attempts: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal attempts: %w", unmarshalErr)
+	}
+	return attempts, nil
+}
+
+// moveAttempt reassigns an attempt to another user, used when merging
+// accounts so the log keeps covering the merged statistics.
+func (s *Store) moveAttempt(attempt Attempt, toUserId string) error {
+	fromUserId := attempt.UserId
+	attempt.UserId = toUserId
+	item, err := dynamodbattribute.MarshalMap(attempt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(AttemptsTableName),
+					Item:      item,
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(AttemptsTableName),
+					Key:       attemptKey(fromUserId, attempt.AttemptId),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// PutWordStatistics replaces a WordStatistics row, used when rebuilding the
+// projection from the attempt log. The row is only written if it still counts
+// prevAttempts (0 if it didn't exist), otherwise a results upload got there
+// first and false is returned.
+func (s *Store) PutWordStatistics(stat WordStatistics, prevAttempts int) (bool, error) {
+	item, err := dynamodbattribute.MarshalMap(stat)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal word statistics: %w", err)
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(WordStatsTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(word) OR attempts = :prevAttempts"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prevAttempts": {N: aws.String(strconv.Itoa(prevAttempts))},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
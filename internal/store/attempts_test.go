@@ -0,0 +1,82 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/projection"
+	"hpmaster/internal/store"
+)
+
+// TestRecordAttempts records an upload and then a retry of it with one more
+// attempt. The retry must only count the new attempt.
+func TestRecordAttempts(t *testing.T) {
+	db := newFakeDB()
+	user := store.User{UserId: fixtures.UserId, Name: "Fixture", XP: 100}
+	seed(t, db, store.UsersTableName, user)
+	s := store.New(db)
+
+	global, verbs := store.BoardKey(), store.BoardKey(store.ScopeCategory, "verb")
+	attempt := func(id string, word string, correct bool, xp int, boards ...string) store.Attempt {
+		answeredAt := "2024-03-15T10:00:0" + id + "Z"
+		return store.Attempt{
+			UserId: fixtures.UserId, AttemptId: answeredAt + "#" + id, Word: word,
+			IsCorrect: correct, XP: xp, Boards: boards, AnsweredAt: answeredAt,
+		}
+	}
+	upload := []store.Attempt{
+		attempt("2", "värna", true, 10, global, verbs),
+		attempt("1", "värna", false, 0, global, verbs),
+		attempt("3", "banal", true, 5, global),
+	}
+
+	recorded, err := s.RecordAttempts(&user, upload, projection.Apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 3 || recorded[0].AttemptId != upload[1].AttemptId {
+		t.Fatalf("recorded %+v, want all three, the first värna first", recorded)
+	}
+
+	retry := append(upload, attempt("4", "värna", true, 10, global, verbs))
+	recorded, err = s.RecordAttempts(&user, retry, projection.Apply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorded) != 1 || recorded[0].AttemptId != retry[3].AttemptId {
+		t.Fatalf("retry recorded %+v, want only the new attempt", recorded)
+	}
+
+	if got := len(db.items(store.AttemptsTableName)); got != 4 {
+		t.Errorf("%d attempts logged, want 4", got)
+	}
+	var users []store.User
+	if err := dynamodbattribute.UnmarshalListOfMaps(db.items(store.UsersTableName), &users); err != nil {
+		t.Fatal(err)
+	}
+	if users[0].XP != 125 {
+		t.Errorf("xp = %d, want 125", users[0].XP)
+	}
+	var stats []store.WordStatistics
+	if err := dynamodbattribute.UnmarshalListOfMaps(db.items(store.WordStatsTableName), &stats); err != nil {
+		t.Fatal(err)
+	}
+	for _, stat := range stats {
+		if stat.Word == "värna" && (stat.Attempts != 3 || stat.Success != 2 || stat.IntervalDays != 2) {
+			t.Errorf("värna = %+v, want 3 attempts, 2 correct in a row", stat)
+		}
+	}
+	scores := map[string]int{}
+	var entries []store.LeaderboardEntry
+	if err := dynamodbattribute.UnmarshalListOfMaps(db.items(store.LeaderboardsTableName), &entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		scores[entry.Board] = entry.Score
+	}
+	if scores[global] != 25 || scores[verbs] != 20 {
+		t.Errorf("scores = %v, want %s 25 and %s 20", scores, global, verbs)
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+const AuditLogTableName = "AuditLog"
+
+// Audited actions
+const (
+	AuditViewAsUser = "viewAsUser"
+	// AuditTeacherReport is a teacher generating a group member's report.
+	AuditTeacherReport = "teacherReport"
+)
+
+// AuditEntry records an access to a user's data by someone else. Entries are
+// stored under the userId of the accessed user, so support can answer who
+// looked at an account.
+type AuditEntry struct {
+	UserId string `json:"userId"`
+	// AuditId starts with At so a user's entries sort chronologically.
+	AuditId    string `json:"auditId"`
+	ActorId    string `json:"actorId"`
+	ActorEmail string `json:"actorEmail"`
+	Action     string `json:"action"`
+	Reason     string `json:"reason,omitempty"`
+	At         string `json:"at"`
+}
+
+// WriteAudit appends an entry to the audit log for userId.
+func (s *Store) WriteAudit(userId string, actor *User, action string, reason string, now time.Time) error {
+	entry := AuditEntry{
+		UserId:     userId,
+		AuditId:    now.UTC().Format(idTimeLayout) + "#" + uuid.New().String(),
+		ActorId:    actor.UserId,
+		ActorEmail: actor.Email,
+		Action:     action,
+		Reason:     reason,
+		At:         now.Format(time.RFC3339),
+	}
+	item, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(AuditLogTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
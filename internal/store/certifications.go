@@ -0,0 +1,208 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const CertificationsTableName = "Certifications"
+
+const (
+	CertificationPending = "pending"
+	CertificationPassed  = "passed"
+	CertificationFailed  = "failed"
+)
+
+var (
+	ErrCertificationNotFound  = errors.New("certification not found")
+	ErrCertificationCompleted = errors.New("certification already completed")
+	ErrCertificationCooldown  = errors.New("certification attempted too recently")
+)
+
+// cooldownPrefix starts the certificationId of the row that records when a
+// user may next attempt a category. It is stored among the certifications so
+// it can be claimed in the same transaction that creates one.
+const cooldownPrefix = "cooldown#"
+
+// Certification is a "test out" session for a category. Once passed it
+// doubles as the certificate.
+type Certification struct {
+	UserId          string   `json:"userId"`
+	CertificationId string   `json:"certificationId"`
+	Category        string   `json:"category"`
+	Words           []string `json:"words"`
+	CreatedAt       string   `json:"createdAt"`
+	ExpiresAt       string   `json:"expiresAt"`
+	Status          string   `json:"status"`
+	Score           float64  `json:"score"`
+	CompletedAt     string   `json:"completedAt,omitempty"`
+}
+
+// CreateCertification stores a pending certification. The user can't start
+// another one of the same category until cooldown has passed, otherwise it
+// fails with ErrCertificationCooldown. Retrying until the sample happens to
+// hold known words would make the certificate meaningless.
+func (s *Store) CreateCertification(cert Certification, cooldown time.Duration, now time.Time) error {
+	item, err := dynamodbattribute.MarshalMap(cert)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName:           aws.String(CertificationsTableName),
+					Key:                 certificationKey(cert.UserId, cooldownPrefix+cert.Category),
+					ConditionExpression: aws.String("attribute_not_exists(retryAt) OR retryAt <= :now"),
+					UpdateExpression:    aws.String("SET retryAt = :retryAt"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":now":     {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+						":retryAt": {N: aws.String(strconv.FormatInt(now.Add(cooldown).Unix(), 10))},
+					},
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(CertificationsTableName),
+					Item:      item,
+				},
+			},
+		},
+	})
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		for _, reason := range aerr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return ErrCertificationCooldown
+			}
+		}
+	}
+	return err
+}
+
+// ListCertificates returns the user's passed certifications, i.e. the
+// certificates of the categories the user mastered.
+func (s *Store) ListCertificates(userId string) ([]Certification, error) {
+	var certs []Certification
+	var unmarshalErr error
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(CertificationsTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		FilterExpression:       aws.String("#status = :passed"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+			":passed": {S: aws.String(CertificationPassed)},
+		},
+	}
+
+	err := s.db.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []Certification
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		certs = append(certs, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certifications: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal certifications: %w", unmarshalErr)
+	}
+	return certs, nil
+}
+
+func (s *Store) GetCertification(userId string, certificationId string) (*Certification, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(CertificationsTableName),
+		Key:       certificationKey(userId, certificationId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrCertificationNotFound
+	}
+
+	var cert Certification
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &cert); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal certification: %w", err)
+	}
+	return &cert, nil
+}
+
+// CompleteCertification records the outcome of a pending certification. A
+// passed certification also marks its category as mastered for the user.
+func (s *Store) CompleteCertification(cert *Certification, passed bool, score float64) error {
+	status := CertificationFailed
+	if passed {
+		status = CertificationPassed
+	}
+	completedAt := time.Now().Format(time.RFC3339)
+
+	items := []*dynamodb.TransactWriteItem{
+		{
+			Update: &dynamodb.Update{
+				TableName:           aws.String(CertificationsTableName),
+				Key:                 certificationKey(cert.UserId, cert.CertificationId),
+				ConditionExpression: aws.String("#status = :pending"),
+				UpdateExpression:    aws.String("SET #status = :status, score = :score, completedAt = :completedAt"),
+				ExpressionAttributeNames: map[string]*string{
+					"#status": aws.String("status"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":pending":     {S: aws.String(CertificationPending)},
+					":status":      {S: aws.String(status)},
+					":score":       {N: aws.String(fmt.Sprintf("%f", score))},
+					":completedAt": {S: aws.String(completedAt)},
+				},
+			},
+		},
+	}
+	if passed {
+		items = append(items, &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName: aws.String(UsersTableName),
+				Key: map[string]*dynamodb.AttributeValue{
+					"userId": {S: aws.String(cert.UserId)},
+				},
+				UpdateExpression: aws.String("ADD masteredCategories :category"),
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":category": {SS: []*string{aws.String(cert.Category)}},
+				},
+			},
+		})
+	}
+
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: items})
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		for _, reason := range aerr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return ErrCertificationCompleted
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	cert.Status = status
+	cert.Score = score
+	cert.CompletedAt = completedAt
+	return nil
+}
+
+func certificationKey(userId string, certificationId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId":          {S: aws.String(userId)},
+		"certificationId": {S: aws.String(certificationId)},
+	}
+}
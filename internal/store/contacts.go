@@ -0,0 +1,182 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const ContactsTableName = "Contacts"
+
+// Contact statuses. A request is stored twice, outgoing under the requester
+// and incoming under the recipient, and both become accepted together.
+const (
+	ContactOutgoing = "outgoing"
+	ContactIncoming = "incoming"
+	ContactAccepted = "accepted"
+)
+
+var ErrContactNotFound = errors.New("contact request not found")
+
+// Contact is one side of a contact between two users, stored under UserId.
+type Contact struct {
+	UserId        string `json:"userId"`
+	ContactUserId string `json:"contactUserId"`
+	// Name is the contact's name when the request was made
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// RequestContact asks to to accept from as a contact. If to already asked
+// from, that request is accepted instead. Repeated requests are no-ops.
+func (s *Store) RequestContact(from *User, to *User, now time.Time) error {
+	existing, err := s.getContact(from.UserId, to.UserId)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if existing.Status == ContactIncoming {
+			return s.AcceptContact(from.UserId, to.UserId, now)
+		}
+		return nil
+	}
+
+	outgoing, err := dynamodbattribute.MarshalMap(Contact{
+		UserId:        from.UserId,
+		ContactUserId: to.UserId,
+		Name:          to.Name,
+		Status:        ContactOutgoing,
+		UpdatedAt:     now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	incoming, err := dynamodbattribute.MarshalMap(Contact{
+		UserId:        to.UserId,
+		ContactUserId: from.UserId,
+		Name:          from.Name,
+		Status:        ContactIncoming,
+		UpdatedAt:     now.Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{Put: &dynamodb.Put{
+				TableName:           aws.String(ContactsTableName),
+				Item:                outgoing,
+				ConditionExpression: aws.String("attribute_not_exists(contactUserId)"),
+			}},
+			{Put: &dynamodb.Put{
+				TableName:           aws.String(ContactsTableName),
+				Item:                incoming,
+				ConditionExpression: aws.String("attribute_not_exists(contactUserId)"),
+			}},
+		},
+	})
+	if _, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		// Requested concurrently, by either side
+		return nil
+	}
+	return err
+}
+
+// AcceptContact accepts the incoming request of contactUserId. It fails with
+// ErrContactNotFound if there is none.
+func (s *Store) AcceptContact(userId string, contactUserId string, now time.Time) error {
+	accept := func(userId string, contactUserId string, status string) *dynamodb.TransactWriteItem {
+		return &dynamodb.TransactWriteItem{
+			Update: &dynamodb.Update{
+				TableName:           aws.String(ContactsTableName),
+				Key:                 contactKey(userId, contactUserId),
+				ConditionExpression: aws.String("#status = :status"),
+				UpdateExpression:    aws.String("SET #status = :accepted, updatedAt = :now"),
+				ExpressionAttributeNames: map[string]*string{
+					"#status": aws.String("status"),
+				},
+				ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+					":status":   {S: aws.String(status)},
+					":accepted": {S: aws.String(ContactAccepted)},
+					":now":      {S: aws.String(now.Format(time.RFC3339))},
+				},
+			},
+		}
+	}
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			accept(userId, contactUserId, ContactIncoming),
+			accept(contactUserId, userId, ContactOutgoing),
+		},
+	})
+	if _, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		return ErrContactNotFound
+	}
+	return err
+}
+
+// ListContacts returns the user's contacts and requests in either direction.
+func (s *Store) ListContacts(userId string) ([]Contact, error) {
+	var contacts []Contact
+	var unmarshalErr error
+	err := s.db.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(ContactsTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []Contact
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		contacts = append(contacts, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query contacts: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal contacts: %w", unmarshalErr)
+	}
+	return contacts, nil
+}
+
+// IsContact reports whether the two users accepted each other as contacts.
+func (s *Store) IsContact(userId string, contactUserId string) (bool, error) {
+	contact, err := s.getContact(userId, contactUserId)
+	if err != nil {
+		return false, err
+	}
+	return contact != nil && contact.Status == ContactAccepted, nil
+}
+
+func (s *Store) getContact(userId string, contactUserId string) (*Contact, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(ContactsTableName),
+		Key:       contactKey(userId, contactUserId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	var contact Contact
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &contact); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal contact: %w", err)
+	}
+	return &contact, nil
+}
+
+func contactKey(userId string, contactUserId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId":        {S: aws.String(userId)},
+		"contactUserId": {S: aws.String(contactUserId)},
+	}
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const DevicesTableName = "Devices"
+
+type Device struct {
+	UserId       string `json:"userId"`
+	DeviceId     string `json:"deviceId"`
+	Platform     string `json:"platform"`
+	AppVersion   string `json:"appVersion"`
+	PushToken    string `json:"pushToken,omitempty"`
+	RegisteredAt string `json:"registeredAt"`
+	LastSeenAt   string `json:"lastSeenAt"`
+	// LastSyncedAt is the sync cursor of the device: the time of the last
+	// results upload the server accepted from it.
+	LastSyncedAt string `json:"lastSyncedAt,omitempty"`
+}
+
+// RegisterDevice creates or updates a device of the user. The registration
+// time and sync cursor of an already known device are kept.
+func (s *Store) RegisterDevice(device Device) (*Device, error) {
+	now := time.Now().Format(time.RFC3339)
+	updateExpression := "SET platform = :platform, appVersion = :appVersion, lastSeenAt = :now, " +
+		"registeredAt = if_not_exists(registeredAt, :now)"
+	values := map[string]*dynamodb.AttributeValue{
+		":platform":   {S: aws.String(device.Platform)},
+		":appVersion": {S: aws.String(device.AppVersion)},
+		":now":        {S: aws.String(now)},
+	}
+	if device.PushToken != "" {
+		updateExpression += ", pushToken = :pushToken"
+		values[":pushToken"] = &dynamodb.AttributeValue{S: aws.String(device.PushToken)}
+	} else {
+		updateExpression += " REMOVE pushToken"
+	}
+
+	result, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(DevicesTableName),
+		Key:                       deviceKey(device.UserId, device.DeviceId),
+		UpdateExpression:          aws.String(updateExpression),
+		ExpressionAttributeValues: values,
+		ReturnValues:              aws.String("ALL_NEW"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var updated Device
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &updated); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal device: %w", err)
+	}
+	return &updated, nil
+}
+
+// MarkDeviceSynced advances the sync cursor of a registered device. Unknown
+// devices are ignored.
+func (s *Store) MarkDeviceSynced(userId string, deviceId string, syncedAt time.Time) error {
+	now := syncedAt.Format(time.RFC3339)
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(DevicesTableName),
+		Key:                 deviceKey(userId, deviceId),
+		ConditionExpression: aws.String("attribute_exists(deviceId)"),
+		UpdateExpression:    aws.String("SET lastSyncedAt = :now, lastSeenAt = :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {S: aws.String(now)},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *Store) ListDevices(userId string) ([]Device, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(DevicesTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query devices: %w", err)
+	}
+
+	var devices []Device
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &devices); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal devices: %w", err)
+	}
+	return devices, nil
+}
+
+func deviceKey(userId string, deviceId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId":   {S: aws.String(userId)},
+		"deviceId": {S: aws.String(deviceId)},
+	}
+}
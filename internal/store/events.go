@@ -0,0 +1,81 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const EventsTableName = "Events"
+
+// Event is a time-boxed special, e.g. a seasonal category with bonus XP and a
+// leaderboard of its own (BoardKey(ScopeEvent, EventId)).
+type Event struct {
+	EventId     string `json:"eventId"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Category limits the event to the words of one category. Empty means
+	// every word takes part.
+	Category string `json:"category,omitempty"`
+	// XPMultiplier applies to the XP of words taking part. 0 means no bonus.
+	XPMultiplier float64 `json:"xpMultiplier,omitempty"`
+	// StartsAt and EndsAt are RFC3339, EndsAt is exclusive.
+	StartsAt string `json:"startsAt"`
+	EndsAt   string `json:"endsAt"`
+}
+
+// IsActive reports whether now is within the event's time box.
+func (e Event) IsActive(now time.Time) bool {
+	startsAt, err := time.Parse(time.RFC3339, e.StartsAt)
+	if err != nil {
+		return false
+	}
+	endsAt, err := time.Parse(time.RFC3339, e.EndsAt)
+	if err != nil {
+		return false
+	}
+	return !now.Before(startsAt) && now.Before(endsAt)
+}
+
+// Includes reports whether a word of the given category takes part.
+func (e Event) Includes(category string) bool {
+	return e.Category == "" || e.Category == category
+}
+
+func (s *Store) ListEvents() ([]Event, error) {
+	var events []Event
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String(EventsTableName),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []Event
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		events = append(events, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan events: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal events: %w", unmarshalErr)
+	}
+	return events, nil
+}
+
+// PutEvent creates or replaces an event.
+func (s *Store) PutEvent(event Event) error {
+	item, err := dynamodbattribute.MarshalMap(event)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(EventsTableName),
+		Item:      item,
+	})
+	return err
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// UserExport is everything stored about a single user, as handed out on a
+// data export request.
+type UserExport struct {
+	ExportedAt      string           `json:"exportedAt"`
+	ResidencyRegion string           `json:"residencyRegion"`
+	User            User             `json:"user"`
+	WordStatistics  []WordStatistics `json:"wordStatistics"`
+	Attempts        []Attempt        `json:"attempts"`
+	Devices         []Device         `json:"devices"`
+	Contacts        []Contact        `json:"contacts"`
+	// Gifts are the gifts the user received. Sent gifts are stored with
+	// their recipients.
+	Gifts              []Gift             `json:"gifts"`
+	Certifications     []Certification    `json:"certifications"`
+	Suggestions        []Suggestion       `json:"suggestions"`
+	LeaderboardEntries []LeaderboardEntry `json:"leaderboardEntries"`
+	// AuditLog lists who accessed the user's data.
+	AuditLog []AuditEntry `json:"auditLog"`
+	// ReportLinks share the reports below.
+	ReportLinks []ReportLink `json:"reportLinks"`
+	// Reports are the rendered progress reports, which are kept in S3 and
+	// added by the caller, see report.Archive.
+	Reports []ExportedReport `json:"reports"`
+}
+
+// ExportedReport is a stored progress report. The body is base64 encoded
+// in JSON.
+type ExportedReport struct {
+	Key          string `json:"key"`
+	ContentType  string `json:"contentType"`
+	LastModified string `json:"lastModified"`
+	Body         []byte `json:"body"`
+}
+
+// ExportUser collects the user's rows of every table. Reports is left for
+// the caller to fill.
+func (s *Store) ExportUser(userId string) (*UserExport, error) {
+	user, err := s.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+	export := &UserExport{
+		ExportedAt:      time.Now().Format(time.RFC3339),
+		ResidencyRegion: user.Residency(),
+		User:            *user,
+	}
+	if export.WordStatistics, err = s.ListWordStatistics(userId); err != nil {
+		return nil, err
+	}
+	if export.Attempts, err = s.ListAttempts(userId); err != nil {
+		return nil, err
+	}
+	if export.Contacts, err = s.ListContacts(userId); err != nil {
+		return nil, err
+	}
+	if export.Suggestions, err = s.listUserSuggestions(userId); err != nil {
+		return nil, err
+	}
+	if export.LeaderboardEntries, err = s.listLeaderboardEntries(userId); err != nil {
+		return nil, err
+	}
+
+	// Rows keyed by userId and an id of their own
+	tables := []struct {
+		table string
+		rows  interface{}
+	}{
+		{DevicesTableName, &export.Devices},
+		{GiftsTableName, &export.Gifts},
+		{CertificationsTableName, &export.Certifications},
+		{AuditLogTableName, &export.AuditLog},
+		{ReportLinksTableName, &export.ReportLinks},
+	}
+	for _, t := range tables {
+		items, err := s.queryUserItems(t.table, userId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", t.table, err)
+		}
+		if err := dynamodbattribute.UnmarshalListOfMaps(items, t.rows); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s: %w", t.table, err)
+		}
+	}
+	return export, nil
+}
+
+// listUserSuggestions returns the suggestions made by the user. Suggestions
+// are keyed by suggestionId, so this scans the table; it is only used for
+// exports.
+func (s *Store) listUserSuggestions(userId string) ([]Suggestion, error) {
+	var suggestions []Suggestion
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName:        aws.String(SuggestionsTableName),
+		FilterExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []Suggestion
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		suggestions = append(suggestions, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan suggestions: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal suggestions: %w", unmarshalErr)
+	}
+	return suggestions, nil
+}
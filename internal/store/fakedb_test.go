@@ -0,0 +1,427 @@
+package store_test
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"hpmaster/internal/store"
+)
+
+type item = map[string]*dynamodb.AttributeValue
+
+// keySchema holds the partition and sort key of the tables the fake knows.
+var keySchema = map[string][]string{
+	store.UsersTableName:          {"userId"},
+	store.WordStatsTableName:      {"userId", "word"},
+	store.AttemptsTableName:       {"userId", "attemptId"},
+	store.DevicesTableName:        {"userId", "deviceId"},
+	store.GiftsTableName:          {"userId", "giftId"},
+	store.CertificationsTableName: {"userId", "certificationId"},
+	store.ContactsTableName:       {"userId", "contactUserId"},
+	store.LeaderboardsTableName:   {"board", "userId"},
+	store.GroupsTableName:         {"groupId"},
+	store.ReportLinksTableName:    {"userId", "token"},
+}
+
+// fakeDB is an in-memory DynamoDB for the store tests. It understands the
+// small subset of expressions the store uses: conditions of comparisons and
+// attribute_(not_)exists joined by AND/OR, and SET, ADD, DELETE and REMOVE
+// updates.
+// Calls it doesn't implement panic through the nil embedded interface.
+type fakeDB struct {
+	dynamodbiface.DynamoDBAPI
+	tables map[string]map[string]item
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{tables: make(map[string]map[string]item)}
+}
+
+func (f *fakeDB) put(table string, it item) {
+	if f.tables[table] == nil {
+		f.tables[table] = make(map[string]item)
+	}
+	f.tables[table][f.key(table, it)] = it
+}
+
+// items returns the rows of a table sorted by key.
+func (f *fakeDB) items(table string) []item {
+	keys := make([]string, 0, len(f.tables[table]))
+	for key := range f.tables[table] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]item, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, f.tables[table][key])
+	}
+	return items
+}
+
+func (f *fakeDB) key(table string, it item) string {
+	schema, ok := keySchema[table]
+	if !ok {
+		panic("unknown table " + table)
+	}
+	parts := make([]string, 0, len(schema))
+	for _, name := range schema {
+		parts = append(parts, aws.StringValue(it[name].S))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func (f *fakeDB) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	it := f.tables[*input.TableName][f.key(*input.TableName, input.Key)]
+	return &dynamodb.GetItemOutput{Item: copyItem(it)}, nil
+}
+
+func (f *fakeDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	write := dynamodb.TransactWriteItem{Put: &dynamodb.Put{
+		TableName:                 input.TableName,
+		Item:                      input.Item,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}
+	return &dynamodb.PutItemOutput{}, f.single(&write)
+}
+
+func (f *fakeDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	write := dynamodb.TransactWriteItem{Delete: &dynamodb.Delete{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}
+	return &dynamodb.DeleteItemOutput{}, f.single(&write)
+}
+
+func (f *fakeDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	write := dynamodb.TransactWriteItem{Update: &dynamodb.Update{
+		TableName:                 input.TableName,
+		Key:                       input.Key,
+		UpdateExpression:          input.UpdateExpression,
+		ConditionExpression:       input.ConditionExpression,
+		ExpressionAttributeNames:  input.ExpressionAttributeNames,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}}
+	return &dynamodb.UpdateItemOutput{}, f.single(&write)
+}
+
+func (f *fakeDB) single(write *dynamodb.TransactWriteItem) error {
+	_, err := f.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: []*dynamodb.TransactWriteItem{write}})
+	if _, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		return &dynamodb.ConditionalCheckFailedException{Message_: aws.String("The conditional request failed")}
+	}
+	return err
+}
+
+// TransactWriteItems checks every condition before writing anything, like
+// DynamoDB does.
+func (f *fakeDB) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+	reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+	failed := false
+	for i, write := range input.TransactItems {
+		reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+		table, key, condition, names, values := writeTarget(write)
+		existing := f.tables[table][f.key(table, key)]
+		ok, err := evalCondition(condition, names, values, existing)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			failed = true
+		}
+	}
+	if failed {
+		return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+	}
+
+	for _, write := range input.TransactItems {
+		switch {
+		case write.Put != nil:
+			f.put(*write.Put.TableName, copyItem(write.Put.Item))
+		case write.Delete != nil:
+			delete(f.tables[*write.Delete.TableName], f.key(*write.Delete.TableName, write.Delete.Key))
+		case write.Update != nil:
+			u := write.Update
+			it := copyItem(f.tables[*u.TableName][f.key(*u.TableName, u.Key)])
+			if it == nil {
+				it = copyItem(u.Key)
+			}
+			if err := applyUpdate(*u.UpdateExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues, it); err != nil {
+				return nil, err
+			}
+			f.put(*u.TableName, it)
+		}
+	}
+	return &dynamodb.TransactWriteItemsOutput{}, nil
+}
+
+func (f *fakeDB) QueryPages(input *dynamodb.QueryInput, fn func(*dynamodb.QueryOutput, bool) bool) error {
+	output, err := f.Query(input)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+	return nil
+}
+
+func (f *fakeDB) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+	if input.IndexName != nil {
+		return nil, fmt.Errorf("fakeDB: index %s not supported", *input.IndexName)
+	}
+	items, err := f.filter(*input.TableName, input.KeyConditionExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{Items: items}, nil
+}
+
+func (f *fakeDB) ScanPages(input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool) error {
+	items, err := f.filter(*input.TableName, input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues)
+	if err != nil {
+		return err
+	}
+	fn(&dynamodb.ScanOutput{Items: items}, true)
+	return nil
+}
+
+func (f *fakeDB) filter(table string, expression *string, names map[string]*string, values item) ([]item, error) {
+	var matched []item
+	for _, it := range f.items(table) {
+		ok, err := evalCondition(expression, names, values, it)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, copyItem(it))
+		}
+	}
+	return matched, nil
+}
+
+func writeTarget(write *dynamodb.TransactWriteItem) (string, item, *string, map[string]*string, item) {
+	switch {
+	case write.Put != nil:
+		p := write.Put
+		return *p.TableName, p.Item, p.ConditionExpression, p.ExpressionAttributeNames, p.ExpressionAttributeValues
+	case write.Delete != nil:
+		d := write.Delete
+		return *d.TableName, d.Key, d.ConditionExpression, d.ExpressionAttributeNames, d.ExpressionAttributeValues
+	case write.Update != nil:
+		u := write.Update
+		return *u.TableName, u.Key, u.ConditionExpression, u.ExpressionAttributeNames, u.ExpressionAttributeValues
+	}
+	panic("fakeDB: unsupported transact item")
+}
+
+var (
+	existsPattern     = regexp.MustCompile(`^(attribute_exists|attribute_not_exists)\((\S+)\)$`)
+	comparePattern    = regexp.MustCompile(`^(\S+) (=|<|<=|>|>=) (:\S+)$`)
+	clausePattern     = regexp.MustCompile(`\b(SET|ADD|DELETE|REMOVE) `)
+	ifNotExistPattern = regexp.MustCompile(`^if_not_exists\((\S+), (:\S+)\)$`)
+)
+
+func evalCondition(expression *string, names map[string]*string, values item, it item) (bool, error) {
+	if expression == nil {
+		return true, nil
+	}
+	for _, alternative := range strings.Split(*expression, " OR ") {
+		all := true
+		for _, term := range strings.Split(alternative, " AND ") {
+			ok, err := evalTerm(strings.TrimSpace(term), names, values, it)
+			if err != nil {
+				return false, err
+			}
+			all = all && ok
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func evalTerm(term string, names map[string]*string, values item, it item) (bool, error) {
+	if m := existsPattern.FindStringSubmatch(term); m != nil {
+		_, exists := it[attributeName(m[2], names)]
+		return exists == (m[1] == "attribute_exists"), nil
+	}
+	m := comparePattern.FindStringSubmatch(term)
+	if m == nil {
+		return false, fmt.Errorf("fakeDB: unsupported condition %q", term)
+	}
+	have, exists := it[attributeName(m[1], names)]
+	if !exists {
+		return false, nil
+	}
+	cmp := compare(have, values[m[3]])
+	switch m[2] {
+	case "=":
+		return cmp == 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func applyUpdate(expression string, names map[string]*string, values item, it item) error {
+	bounds := clausePattern.FindAllStringSubmatchIndex(expression, -1)
+	for i, bound := range bounds {
+		end := len(expression)
+		if i+1 < len(bounds) {
+			end = bounds[i+1][0]
+		}
+		clause := expression[bound[2]:bound[3]]
+		for _, action := range splitActions(expression[bound[1]:end]) {
+			switch clause {
+			case "SET":
+				parts := strings.SplitN(action, " = ", 2)
+				name := attributeName(parts[0], names)
+				if m := ifNotExistPattern.FindStringSubmatch(parts[1]); m != nil {
+					if _, exists := it[name]; !exists {
+						it[name] = values[m[2]]
+					}
+					continue
+				}
+				it[name] = values[parts[1]]
+			case "ADD":
+				parts := strings.Fields(action)
+				name := attributeName(parts[0], names)
+				it[name] = add(it[name], values[parts[1]])
+			case "DELETE":
+				parts := strings.Fields(action)
+				name := attributeName(parts[0], names)
+				if left := remove(it[name], values[parts[1]]); left != nil {
+					it[name] = left
+				} else {
+					delete(it, name)
+				}
+			case "REMOVE":
+				delete(it, attributeName(action, names))
+			}
+		}
+	}
+	return nil
+}
+
+// splitActions splits the comma separated actions of a clause, leaving the
+// commas inside function calls alone.
+func splitActions(s string) []string {
+	var actions []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				actions = append(actions, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(actions, strings.TrimSpace(s[start:]))
+}
+
+func add(have *dynamodb.AttributeValue, value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if value.SS != nil {
+		set := map[string]bool{}
+		var merged []*string
+		if have != nil {
+			merged = append(merged, have.SS...)
+			for _, s := range have.SS {
+				set[*s] = true
+			}
+		}
+		for _, s := range value.SS {
+			if !set[*s] {
+				merged = append(merged, s)
+			}
+		}
+		return &dynamodb.AttributeValue{SS: merged}
+	}
+	sum := number(value)
+	if have != nil {
+		sum += number(have)
+	}
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatFloat(sum, 'f', -1, 64))}
+}
+
+// remove deletes the strings of value from the set have. Sets can't be
+// empty, so nil is returned when nothing is left.
+func remove(have *dynamodb.AttributeValue, value *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	if have == nil {
+		return nil
+	}
+	deleted := map[string]bool{}
+	for _, s := range value.SS {
+		deleted[*s] = true
+	}
+	var left []*string
+	for _, s := range have.SS {
+		if !deleted[*s] {
+			left = append(left, s)
+		}
+	}
+	if len(left) == 0 {
+		return nil
+	}
+	return &dynamodb.AttributeValue{SS: left}
+}
+
+func compare(a *dynamodb.AttributeValue, b *dynamodb.AttributeValue) int {
+	if a.N != nil && b.N != nil {
+		switch x, y := number(a), number(b); {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(aws.StringValue(a.S), aws.StringValue(b.S))
+}
+
+func number(v *dynamodb.AttributeValue) float64 {
+	n, err := strconv.ParseFloat(aws.StringValue(v.N), 64)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func attributeName(name string, names map[string]*string) string {
+	if strings.HasPrefix(name, "#") {
+		return aws.StringValue(names[name])
+	}
+	return name
+}
+
+func copyItem(it item) item {
+	if it == nil {
+		return nil
+	}
+	c := make(item, len(it))
+	for name, value := range it {
+		c[name] = value
+	}
+	return c
+}
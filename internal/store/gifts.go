@@ -0,0 +1,215 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/streak"
+)
+
+const GiftsTableName = "Gifts"
+
+// Gift kinds
+const (
+	GiftStreakFreeze = "streakFreeze"
+	GiftXPBoost      = "xpBoost"
+)
+
+const (
+	GiftPending = "pending"
+	GiftClaimed = "claimed"
+)
+
+// MaxXPBoosts is how many unused XP boosts a user can hold.
+const MaxXPBoosts = 5
+
+var (
+	ErrGiftNotFound    = errors.New("gift not found")
+	ErrGiftAlreadySent = errors.New("gift already sent today")
+	ErrGiftClaimed     = errors.New("gift already claimed")
+	ErrInventoryFull   = errors.New("inventory full")
+)
+
+// Gift is sent from one user to another and lands in the recipient's
+// inventory once claimed. It is stored under the recipient's userId.
+type Gift struct {
+	UserId string `json:"userId"`
+	// GiftId is unique per day, sender and kind, so every user can send
+	// each kind of gift to a contact once a day.
+	GiftId     string `json:"giftId"`
+	FromUserId string `json:"fromUserId"`
+	FromName   string `json:"fromName"`
+	Kind       string `json:"kind"`
+	Status     string `json:"status"`
+	SentAt     string `json:"sentAt"`
+	ClaimedAt  string `json:"claimedAt,omitempty"`
+}
+
+// SendGift stores a pending gift for the recipient.
+func (s *Store) SendGift(from *User, toUserId string, kind string, now time.Time) (*Gift, error) {
+	gift := Gift{
+		UserId:     toUserId,
+		GiftId:     streak.Day(now).Format(streak.DateLayout) + "#" + from.UserId + "#" + kind,
+		FromUserId: from.UserId,
+		FromName:   from.Name,
+		Kind:       kind,
+		Status:     GiftPending,
+		SentAt:     now.Format(time.RFC3339),
+	}
+	item, err := dynamodbattribute.MarshalMap(gift)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(GiftsTableName),
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(giftId)"),
+	})
+	if isConditionalCheckFailed(err) {
+		return nil, ErrGiftAlreadySent
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gift, nil
+}
+
+// ListPendingGifts returns the gifts the user has not claimed yet.
+func (s *Store) ListPendingGifts(userId string) ([]Gift, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(GiftsTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		FilterExpression:       aws.String("#status = :pending"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId":  {S: aws.String(userId)},
+			":pending": {S: aws.String(GiftPending)},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gifts: %w", err)
+	}
+
+	var gifts []Gift
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &gifts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gifts: %w", err)
+	}
+	return gifts, nil
+}
+
+// ClaimGift marks a pending gift as claimed and adds it to the user's
+// inventory. A gift can't be claimed while the user already holds
+// streak.MaxFreezes streak freezes or MaxXPBoosts boosts, it fails with
+// ErrInventoryFull and stays pending.
+func (s *Store) ClaimGift(userId string, giftId string) (*Gift, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(GiftsTableName),
+		Key:       giftKey(userId, giftId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrGiftNotFound
+	}
+	var gift Gift
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &gift); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal gift: %w", err)
+	}
+	if gift.Status != GiftPending {
+		return nil, ErrGiftClaimed
+	}
+
+	inventory := &dynamodb.Update{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		UpdateExpression:    aws.String("ADD xpBoosts :one"),
+		ConditionExpression: aws.String("attribute_not_exists(xpBoosts) OR xpBoosts < :max"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":one": {N: aws.String("1")},
+			":max": {N: aws.String(strconv.Itoa(MaxXPBoosts))},
+		},
+	}
+	if gift.Kind == GiftStreakFreeze {
+		inventory.UpdateExpression = aws.String("ADD streakFreezes :one")
+		inventory.ConditionExpression = aws.String("attribute_not_exists(streakFreezes) OR streakFreezes < :max")
+		inventory.ExpressionAttributeValues[":max"].N = aws.String(strconv.Itoa(streak.MaxFreezes))
+	}
+
+	gift.Status = GiftClaimed
+	gift.ClaimedAt = time.Now().Format(time.RFC3339)
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName:           aws.String(GiftsTableName),
+					Key:                 giftKey(userId, giftId),
+					ConditionExpression: aws.String("#status = :pending"),
+					UpdateExpression:    aws.String("SET #status = :claimed, claimedAt = :claimedAt"),
+					ExpressionAttributeNames: map[string]*string{
+						"#status": aws.String("status"),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":pending":   {S: aws.String(GiftPending)},
+						":claimed":   {S: aws.String(GiftClaimed)},
+						":claimedAt": {S: aws.String(gift.ClaimedAt)},
+					},
+				},
+			},
+			{Update: inventory},
+		},
+	})
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok && len(aerr.CancellationReasons) == 2 {
+		if code := aerr.CancellationReasons[0].Code; code != nil && *code == "ConditionalCheckFailed" {
+			return nil, ErrGiftClaimed
+		}
+		if code := aerr.CancellationReasons[1].Code; code != nil && *code == "ConditionalCheckFailed" {
+			return nil, ErrInventoryFull
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &gift, nil
+}
+
+// UseXPBoost spends one of the user's XP boosts. It reports false if the user
+// has none.
+func (s *Store) UseXPBoost(userId string) (bool, error) {
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		ConditionExpression: aws.String("xpBoosts > :zero"),
+		UpdateExpression:    aws.String("ADD xpBoosts :minusOne"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":zero":     {N: aws.String("0")},
+			":minusOne": {N: aws.String("-1")},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func giftKey(userId string, giftId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId": {S: aws.String(userId)},
+		"giftId": {S: aws.String(giftId)},
+	}
+}
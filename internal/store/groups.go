@@ -0,0 +1,175 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const GroupsTableName = "Groups"
+
+var ErrGroupNotFound = errors.New("group not found")
+
+// Group is a classroom. Its members rank against each other on every deck
+// the teacher assigned, on BoardKey(ScopeGroup, GroupId, ScopeDeck, deckId).
+//
+// Membership is stored on both sides: Members on the group for the teacher,
+// and User.Groups for the words lambda, which writes the group boards.
+type Group struct {
+	GroupId   string `json:"groupId"`
+	Name      string `json:"name"`
+	TeacherId string `json:"teacherId"`
+	// Decks are the packIds assigned to the group.
+	Decks   []string `json:"decks,omitempty" dynamodbav:"decks,stringset,omitempty"`
+	Members []string `json:"members,omitempty" dynamodbav:"members,stringset,omitempty"`
+}
+
+// HasMember reports whether the user is a member of the group.
+func (g *Group) HasMember(userId string) bool {
+	for _, member := range g.Members {
+		if member == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// Boards returns the keys of the group's deck boards.
+func (g *Group) Boards() []string {
+	boards := make([]string, 0, len(g.Decks))
+	for _, deckId := range g.Decks {
+		boards = append(boards, BoardKey(ScopeGroup, g.GroupId, ScopeDeck, deckId))
+	}
+	return boards
+}
+
+// BoardGroup returns the groupId of a group board, "" for other boards.
+func BoardGroup(board string) string {
+	parts := strings.Split(board, "#")
+	if len(parts) < 2 || parts[0] != ScopeGroup {
+		return ""
+	}
+	return parts[1]
+}
+
+func (s *Store) GetGroup(groupId string) (*Group, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(GroupsTableName),
+		Key:       groupKey(groupId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrGroupNotFound
+	}
+
+	var group Group
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &group); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal group: %w", err)
+	}
+	return &group, nil
+}
+
+// PutGroup creates a group or updates its name, teacher and decks. Members
+// are kept, they change with AddGroupMember and RemoveGroupMember only.
+func (s *Store) PutGroup(group Group) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:        aws.String(GroupsTableName),
+		Key:              groupKey(group.GroupId),
+		UpdateExpression: aws.String("SET #name = :name, teacherId = :teacherId REMOVE decks"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":name":      {S: aws.String(group.Name)},
+			":teacherId": {S: aws.String(group.TeacherId)},
+		},
+	}
+	// String sets can't be empty
+	if len(group.Decks) > 0 {
+		input.UpdateExpression = aws.String("SET #name = :name, teacherId = :teacherId, decks = :decks")
+		input.ExpressionAttributeValues[":decks"] = &dynamodb.AttributeValue{SS: aws.StringSlice(group.Decks)}
+	}
+	_, err := s.db.UpdateItem(input)
+	return err
+}
+
+// AddGroupMember adds the user to the group, on both sides in one
+// transaction. It fails with ErrGroupNotFound or ErrUserNotFound.
+func (s *Store) AddGroupMember(groupId string, userId string) error {
+	err := s.setGroupMember(groupId, userId, "ADD")
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok && len(aerr.CancellationReasons) == 2 {
+		if code := aerr.CancellationReasons[0].Code; code != nil && *code == "ConditionalCheckFailed" {
+			return ErrGroupNotFound
+		}
+		if code := aerr.CancellationReasons[1].Code; code != nil && *code == "ConditionalCheckFailed" {
+			return ErrUserNotFound
+		}
+	}
+	return err
+}
+
+// RemoveGroupMember takes the user out of the group and off its boards.
+func (s *Store) RemoveGroupMember(groupId string, userId string) error {
+	group, err := s.GetGroup(groupId)
+	if err != nil {
+		return err
+	}
+	if err := s.setGroupMember(groupId, userId, "DELETE"); err != nil {
+		return err
+	}
+	for _, board := range group.Boards() {
+		if err := s.deleteLeaderboardEntry(board, userId); err != nil {
+			return fmt.Errorf("failed to remove %s from %s: %w", userId, board, err)
+		}
+	}
+	return nil
+}
+
+// setGroupMember adds the user to (action ADD) or deletes it from (DELETE)
+// the members of the group and the groups of the user.
+func (s *Store) setGroupMember(groupId string, userId string, action string) error {
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName:           aws.String(GroupsTableName),
+					Key:                 groupKey(groupId),
+					ConditionExpression: aws.String("attribute_exists(groupId)"),
+					UpdateExpression:    aws.String(action + " members :member"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":member": {SS: []*string{aws.String(userId)}},
+					},
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(UsersTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"userId": {S: aws.String(userId)},
+					},
+					ConditionExpression: aws.String("attribute_exists(userId)"),
+					UpdateExpression:    aws.String(action + " #groups :group"),
+					ExpressionAttributeNames: map[string]*string{
+						"#groups": aws.String("groups"),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":group": {SS: []*string{aws.String(groupId)}},
+					},
+				},
+			},
+		},
+	})
+	return err
+}
+
+func groupKey(groupId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"groupId": {S: aws.String(groupId)},
+	}
+}
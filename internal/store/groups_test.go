@@ -0,0 +1,64 @@
+package store_test
+
+import (
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/store"
+)
+
+// TestGroupMembers adds a duplicate account to a class, merges it into the
+// fixture user and takes the fixture user out of the class again.
+func TestGroupMembers(t *testing.T) {
+	db := newFakeDB()
+	seed(t, db, store.UsersTableName,
+		store.User{UserId: fixtures.UserId, Name: "Fixture"},
+		store.User{UserId: duplicateId, Name: "Duplicate"},
+	)
+	s := store.New(db)
+	group := store.Group{GroupId: "class-7b", Name: "7B", TeacherId: "fixture-teacher", Decks: []string{"hp-2023"}}
+	if err := s.PutGroup(group); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroupMember(group.GroupId, duplicateId); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGroupMember("class-9a", duplicateId); err != store.ErrGroupNotFound {
+		t.Errorf("adding to a missing group returned %v, want ErrGroupNotFound", err)
+	}
+	board := group.Boards()[0]
+	if err := s.AddLeaderboardScore(board, duplicateId, "Duplicate", 40); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.MergeUsers(duplicateId, fixtures.UserId); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.GetGroup(group.GroupId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Members) != 1 || !stored.HasMember(fixtures.UserId) {
+		t.Errorf("members = %v, want only %s", stored.Members, fixtures.UserId)
+	}
+	state := dump(t, db)
+	for _, user := range state.Users {
+		if inGroup := len(user.Groups) == 1 && user.Groups[0] == group.GroupId; inGroup != (user.UserId == fixtures.UserId) {
+			t.Errorf("groups of %s = %v", user.UserId, user.Groups)
+		}
+	}
+	if len(state.Leaderboards) != 1 || state.Leaderboards[0].UserId != fixtures.UserId || state.Leaderboards[0].Score != 40 {
+		t.Errorf("leaderboards = %+v, want the score moved to %s", state.Leaderboards, fixtures.UserId)
+	}
+
+	if err := s.RemoveGroupMember(group.GroupId, fixtures.UserId); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err = s.GetGroup(group.GroupId); err != nil {
+		t.Fatal(err)
+	}
+	state = dump(t, db)
+	if len(stored.Members) != 0 || len(state.Leaderboards) != 0 {
+		t.Errorf("left members %v and entries %+v", stored.Members, state.Leaderboards)
+	}
+}
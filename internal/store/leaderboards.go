@@ -0,0 +1,169 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	LeaderboardsTableName = "Leaderboards"
+
+	leaderboardScoreIndex = "board-score-index"
+)
+
+// Leaderboard scopes. A board key is built from (scope, id) pairs, e.g.
+// BoardKey(ScopeDeck, deckId) ranks the XP earned on a deck, and
+// BoardKey(ScopeGroup, groupId, ScopeDeck, deckId) the XP the members of a
+// group earned on a deck assigned to it, see Group.
+const (
+	ScopeGlobal   = "global"
+	ScopeCategory = "category"
+	ScopeDeck     = "deck"
+	ScopeEvent    = "event"
+	ScopeGroup    = "group"
+)
+
+type LeaderboardEntry struct {
+	Board     string `json:"board"`
+	UserId    string `json:"userId"`
+	Name      string `json:"name"`
+	Score     int    `json:"score"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// BoardKey joins scope/id pairs into the partition key of a leaderboard.
+// Called without arguments it returns the global board.
+func BoardKey(scopeAndIds ...string) string {
+	if len(scopeAndIds) == 0 {
+		return ScopeGlobal
+	}
+	return strings.Join(scopeAndIds, "#")
+}
+
+// AddLeaderboardScore adds score to the user's entry on the board.
+func (s *Store) AddLeaderboardScore(board string, userId string, name string, score int) error {
+	update := leaderboardScoreUpdate(board, userId, name, score)
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 update.TableName,
+		Key:                       update.Key,
+		UpdateExpression:          update.UpdateExpression,
+		ExpressionAttributeNames:  update.ExpressionAttributeNames,
+		ExpressionAttributeValues: update.ExpressionAttributeValues,
+	})
+	return err
+}
+
+// leaderboardScoreUpdate adds score to the user's entry on the board.
+func leaderboardScoreUpdate(board string, userId string, name string, score int) *dynamodb.Update {
+	return &dynamodb.Update{
+		TableName:        aws.String(LeaderboardsTableName),
+		Key:              leaderboardKey(board, userId),
+		UpdateExpression: aws.String("ADD score :score SET #name = :name, updatedAt = :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":score": {N: aws.String(strconv.Itoa(score))},
+			":name":  {S: aws.String(name)},
+			":now":   {S: aws.String(time.Now().Format(time.RFC3339))},
+		},
+	}
+}
+
+// TopLeaderboard returns the limit highest scores of the board.
+func (s *Store) TopLeaderboard(board string, limit int) ([]LeaderboardEntry, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(LeaderboardsTableName),
+		IndexName:              aws.String(leaderboardScoreIndex),
+		KeyConditionExpression: aws.String("board = :board"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":board": {S: aws.String(board)},
+		},
+		ScanIndexForward: aws.Bool(false), // Highest score first
+		Limit:            aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query leaderboard: %w", err)
+	}
+
+	var entries []LeaderboardEntry
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal leaderboard: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *Store) GetLeaderboardEntry(board string, userId string) (*LeaderboardEntry, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(LeaderboardsTableName),
+		Key:       leaderboardKey(board, userId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var entry LeaderboardEntry
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &entry); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal leaderboard entry: %w", err)
+	}
+	return &entry, nil
+}
+
+// LeaderboardRank returns the rank a score has on the board: one more than
+// the number of entries scoring higher.
+func (s *Store) LeaderboardRank(board string, score int) (int, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(LeaderboardsTableName),
+		IndexName:              aws.String(leaderboardScoreIndex),
+		KeyConditionExpression: aws.String("board = :board AND score > :score"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":board": {S: aws.String(board)},
+			":score": {N: aws.String(strconv.Itoa(score))},
+		},
+		Select: aws.String(dynamodb.SelectCount),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count leaderboard: %w", err)
+	}
+	return int(aws.Int64Value(result.Count)) + 1, nil
+}
+
+// RemoveLeaderboardEntries takes the user off every board, e.g. when the
+// user is disabled. Rebuilding the user's statistics from the attempt log
+// puts the scores back.
+func (s *Store) RemoveLeaderboardEntries(userId string) (int, error) {
+	entries, err := s.listLeaderboardEntries(userId)
+	if err != nil {
+		return 0, err
+	}
+	for i, entry := range entries {
+		if err := s.deleteLeaderboardEntry(entry.Board, userId); err != nil {
+			return i, fmt.Errorf("failed to remove %s from %s: %w", userId, entry.Board, err)
+		}
+	}
+	return len(entries), nil
+}
+
+func (s *Store) deleteLeaderboardEntry(board string, userId string) error {
+	_, err := s.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(LeaderboardsTableName),
+		Key:       leaderboardKey(board, userId),
+	})
+	return err
+}
+
+func leaderboardKey(board string, userId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"board":  {S: aws.String(board)},
+		"userId": {S: aws.String(userId)},
+	}
+}
@@ -0,0 +1,26 @@
+package store_test
+
+import (
+	"testing"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/store"
+)
+
+func TestRemoveLeaderboardEntries(t *testing.T) {
+	db := newFakeDB()
+	seed(t, db, store.LeaderboardsTableName,
+		store.LeaderboardEntry{Board: store.BoardKey(), UserId: fixtures.UserId, Name: "Fixture", Score: 120},
+		store.LeaderboardEntry{Board: store.BoardKey(store.ScopeDeck, "hp-2023"), UserId: fixtures.UserId, Name: "Fixture", Score: 30},
+		store.LeaderboardEntry{Board: store.BoardKey(), UserId: "fixture-friend", Name: "Friend", Score: 80},
+	)
+
+	removed, err := store.New(db).RemoveLeaderboardEntries(fixtures.UserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := dump(t, db).Leaderboards
+	if removed != 2 || len(left) != 1 || left[0].UserId != "fixture-friend" {
+		t.Errorf("removed %d, left %+v", removed, left)
+	}
+}
@@ -0,0 +1,498 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/streak"
+)
+
+type MergeResult struct {
+	FromUserId           string `json:"fromUserId"`
+	ToUserId             string `json:"toUserId"`
+	MergedWords          int    `json:"mergedWords"`
+	MergedAttempts       int    `json:"mergedAttempts"`
+	MergedDevices        int    `json:"mergedDevices"`
+	MergedGifts          int    `json:"mergedGifts"`
+	MergedCertifications int    `json:"mergedCertifications"`
+	MergedReportLinks    int    `json:"mergedReportLinks"`
+	MergedContacts       int    `json:"mergedContacts"`
+	MergedLeaderboards   int    `json:"mergedLeaderboards"`
+	MergedGroups         int    `json:"mergedGroups"`
+	MergedXP             int    `json:"mergedXp"`
+}
+
+// MergeUsers moves everything stored for fromUserId into toUserId and marks
+// fromUserId as merged so that lookups by email no longer return it: word
+// statistics, attempts, devices, received gifts, certifications, report
+// links, contacts, leaderboard scores and group memberships, and on the user
+// itself XP, streak, freezes, vacation, XP boosts, installed packs and
+// mastered categories. Both users must share a residency.
+//
+// Every row is moved in its own transaction (add to target, delete from
+// source), so an interrupted merge can simply be run again. The user fields
+// are merged last, together with marking fromUserId as merged.
+func (s *Store) MergeUsers(fromUserId string, toUserId string) (*MergeResult, error) {
+	if fromUserId == toUserId {
+		return nil, errors.New("cannot merge a user into itself")
+	}
+
+	from, err := s.GetUser(fromUserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", fromUserId, err)
+	}
+	to, err := s.GetUser(toUserId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s: %w", toUserId, err)
+	}
+	if to.MergedInto != "" {
+		return nil, fmt.Errorf("user %s is already merged into %s", toUserId, to.MergedInto)
+	}
+	if from.MergedInto != "" && from.MergedInto != toUserId {
+		return nil, fmt.Errorf("user %s is already merged into %s", fromUserId, from.MergedInto)
+	}
+	// Merging must not move data out of the region it resides in
+	if from.Residency() != to.Residency() {
+		return nil, fmt.Errorf("users %s and %s reside in %s and %s", fromUserId, toUserId, from.Residency(), to.Residency())
+	}
+	if err := s.CheckResidency(to.Residency()); err != nil {
+		return nil, fmt.Errorf("failed to merge into user %s: %w", toUserId, err)
+	}
+
+	stats, err := s.ListWordStatistics(fromUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MergeResult{FromUserId: fromUserId, ToUserId: toUserId}
+	for _, stat := range stats {
+		if err := s.moveWordStatistics(stat, toUserId); err != nil {
+			return result, fmt.Errorf("failed to merge word %s: %w", stat.Word, err)
+		}
+		result.MergedWords++
+	}
+
+	attempts, err := s.ListAttempts(fromUserId)
+	if err != nil {
+		return result, err
+	}
+	for _, attempt := range attempts {
+		if err := s.moveAttempt(attempt, toUserId); err != nil {
+			return result, fmt.Errorf("failed to merge attempt %s: %w", attempt.AttemptId, err)
+		}
+		result.MergedAttempts++
+	}
+
+	// Rows keyed by userId and an id of their own. Where the target already
+	// has a row with the same id, e.g. a device signed in to both accounts,
+	// the target's row is kept.
+	moved := []struct {
+		table   string
+		sortKey string
+		count   *int
+	}{
+		{DevicesTableName, "deviceId", &result.MergedDevices},
+		{GiftsTableName, "giftId", &result.MergedGifts},
+		{CertificationsTableName, "certificationId", &result.MergedCertifications},
+		{ReportLinksTableName, "token", &result.MergedReportLinks},
+	}
+	for _, rows := range moved {
+		items, err := s.queryUserItems(rows.table, fromUserId)
+		if err != nil {
+			return result, fmt.Errorf("failed to query %s: %w", rows.table, err)
+		}
+		for _, item := range items {
+			if err := s.moveItem(rows.table, rows.sortKey, item, toUserId); err != nil {
+				return result, fmt.Errorf("failed to merge %s %s: %w", rows.table, aws.StringValue(item[rows.sortKey].S), err)
+			}
+			*rows.count++
+		}
+	}
+
+	contacts, err := s.ListContacts(fromUserId)
+	if err != nil {
+		return result, err
+	}
+	for _, contact := range contacts {
+		if err := s.moveContact(contact, to); err != nil {
+			return result, fmt.Errorf("failed to merge contact %s: %w", contact.ContactUserId, err)
+		}
+		result.MergedContacts++
+	}
+
+	if err := s.moveLeaderboardEntries(fromUserId, to, result); err != nil {
+		return result, err
+	}
+
+	for _, groupId := range from.Groups {
+		if err := s.AddGroupMember(groupId, toUserId); err != nil && err != ErrGroupNotFound {
+			return result, fmt.Errorf("failed to merge group %s: %w", groupId, err)
+		}
+		if err := s.setGroupMember(groupId, fromUserId, "DELETE"); err != nil {
+			return result, fmt.Errorf("failed to merge group %s: %w", groupId, err)
+		}
+		result.MergedGroups++
+	}
+
+	if from.MergedInto == "" {
+		if err := s.tombstoneUser(from, to); err != nil {
+			return result, fmt.Errorf("failed to tombstone user %s: %w", fromUserId, err)
+		}
+		result.MergedXP = from.XP
+	}
+	// An upload that raced the merge can have scored for fromUserId again. It
+	// is refused from now on, so what is left is moved for good and the
+	// merged user never stays on a board.
+	if err := s.moveLeaderboardEntries(fromUserId, to, result); err != nil {
+		return result, err
+	}
+	log.Printf("Merged user %s into %s (%d words)", fromUserId, toUserId, result.MergedWords)
+	return result, nil
+}
+
+func (s *Store) moveWordStatistics(stat WordStatistics, toUserId string) error {
+	target, err := s.getWordStatistics(toUserId, stat.Word)
+	if err != nil {
+		return err
+	}
+	item, err := dynamodbattribute.MarshalMap(mergeWordStatistics(stat, target, toUserId))
+	if err != nil {
+		return fmt.Errorf("failed to marshal word statistics: %w", err)
+	}
+	prevAttempts := 0
+	if target != nil {
+		prevAttempts = target.Attempts
+	}
+
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(WordStatsTableName),
+					Item:      item,
+					// A results upload for the target in between fails the
+					// merge of this word, running it again picks it up
+					ConditionExpression: aws.String("attribute_not_exists(word) OR attempts = :prevAttempts"),
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":prevAttempts": {N: aws.String(strconv.Itoa(prevAttempts))},
+					},
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(WordStatsTableName),
+					Key:       wordStatsKey(stat.UserId, stat.Word),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// mergeWordStatistics adds up the counters of both rows. The review schedule
+// is taken from the row practiced last, and the word was first seen when
+// either account first saw it.
+func mergeWordStatistics(stat WordStatistics, target *WordStatistics, toUserId string) WordStatistics {
+	merged := stat
+	merged.UserId = toUserId
+	if target == nil {
+		return merged
+	}
+
+	merged.Attempts += target.Attempts
+	merged.Success += target.Success
+	merged.SuccessRatio = SuccessRatio(merged.Success, merged.Attempts)
+	if CompareTimestamps(target.LastPracticedAt, stat.LastPracticedAt) > 0 {
+		merged.IntervalDays = target.IntervalDays
+		merged.NextReviewAt = target.NextReviewAt
+		merged.LastPracticedAt = target.LastPracticedAt
+	}
+	if merged.FirstSeenAt == "" || (target.FirstSeenAt != "" && CompareTimestamps(target.FirstSeenAt, stat.FirstSeenAt) < 0) {
+		merged.FirstSeenAt = target.FirstSeenAt
+	}
+	return merged
+}
+
+// queryUserItems returns the items a table stores under userId.
+func (s *Store) queryUserItems(table string, userId string) ([]map[string]*dynamodb.AttributeValue, error) {
+	var items []map[string]*dynamodb.AttributeValue
+	err := s.db.QueryPages(&dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return items, err
+}
+
+// moveItem stores an item of a table keyed by userId and sortKey under
+// toUserId instead. If the target already has an item with the same sort
+// key, that one is kept and the source is only deleted.
+func (s *Store) moveItem(table string, sortKey string, item map[string]*dynamodb.AttributeValue, toUserId string) error {
+	source := map[string]*dynamodb.AttributeValue{
+		"userId": item["userId"],
+		sortKey:  item[sortKey],
+	}
+	moved := make(map[string]*dynamodb.AttributeValue, len(item))
+	for name, value := range item {
+		moved[name] = value
+	}
+	moved["userId"] = &dynamodb.AttributeValue{S: aws.String(toUserId)}
+
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Put: &dynamodb.Put{
+					TableName:           aws.String(table),
+					Item:                moved,
+					ConditionExpression: aws.String("attribute_not_exists(userId)"),
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(table),
+					Key:       source,
+				},
+			},
+		},
+	})
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok && len(aerr.CancellationReasons) == 2 {
+		if code := aerr.CancellationReasons[0].Code; code != nil && *code == "ConditionalCheckFailed" {
+			_, err = s.db.DeleteItem(&dynamodb.DeleteItemInput{
+				TableName: aws.String(table),
+				Key:       source,
+			})
+		}
+	}
+	return err
+}
+
+// moveContact hands a contact of the merged user over to the target user, on
+// both sides of it. If the target already has a contact with the same user,
+// the accepted one of the two is kept, the target's if both are. A contact
+// whose mirror is missing, e.g. left behind by an interrupted removal, is
+// dropped.
+func (s *Store) moveContact(contact Contact, to *User) error {
+	fromUserId, toUserId := contact.UserId, to.UserId
+	deletes := []*dynamodb.TransactWriteItem{
+		{Delete: &dynamodb.Delete{TableName: aws.String(ContactsTableName), Key: contactKey(fromUserId, contact.ContactUserId)}},
+		{Delete: &dynamodb.Delete{TableName: aws.String(ContactsTableName), Key: contactKey(contact.ContactUserId, fromUserId)}},
+	}
+
+	keep := contact.ContactUserId != toUserId
+	if keep {
+		existing, err := s.getContact(toUserId, contact.ContactUserId)
+		if err != nil {
+			return err
+		}
+		keep = existing == nil || (existing.Status != ContactAccepted && contact.Status == ContactAccepted)
+	}
+	if !keep {
+		_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{TransactItems: deletes})
+		return err
+	}
+
+	mirror, err := s.getContact(contact.ContactUserId, fromUserId)
+	if err != nil {
+		return err
+	}
+	if mirror == nil {
+		log.Printf("Dropping contact %s of %s, it has no mirror", contact.ContactUserId, fromUserId)
+		_, err := s.db.DeleteItem(&dynamodb.DeleteItemInput{
+			TableName: aws.String(ContactsTableName),
+			Key:       contactKey(fromUserId, contact.ContactUserId),
+		})
+		return err
+	}
+	contact.UserId = toUserId
+	mirror.ContactUserId = toUserId
+	mirror.Name = to.Name
+	puts := make([]*dynamodb.TransactWriteItem, 0, 2)
+	for _, row := range []Contact{contact, *mirror} {
+		item, err := dynamodbattribute.MarshalMap(row)
+		if err != nil {
+			return err
+		}
+		puts = append(puts, &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{TableName: aws.String(ContactsTableName), Item: item},
+		})
+	}
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: append(puts, deletes...),
+	})
+	return err
+}
+
+// listLeaderboardEntries returns the user's entries on every board. Boards
+// are keyed by board, so this scans the table; it is only used when merging,
+// exporting and disabling.
+func (s *Store) listLeaderboardEntries(userId string) ([]LeaderboardEntry, error) {
+	var entries []LeaderboardEntry
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName:        aws.String(LeaderboardsTableName),
+		FilterExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []LeaderboardEntry
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		entries = append(entries, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan leaderboards: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal leaderboards: %w", unmarshalErr)
+	}
+	return entries, nil
+}
+
+// moveLeaderboardEntries moves every leaderboard entry of the merged user to
+// the target.
+func (s *Store) moveLeaderboardEntries(fromUserId string, to *User, result *MergeResult) error {
+	entries, err := s.listLeaderboardEntries(fromUserId)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := s.moveLeaderboardEntry(entry, to); err != nil {
+			return fmt.Errorf("failed to merge leaderboard %s: %w", entry.Board, err)
+		}
+		result.MergedLeaderboards++
+	}
+	return nil
+}
+
+// moveLeaderboardEntry adds the score of a merged user's entry to the
+// target's entry on the same board.
+func (s *Store) moveLeaderboardEntry(entry LeaderboardEntry, to *User) error {
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName:        aws.String(LeaderboardsTableName),
+					Key:              leaderboardKey(entry.Board, to.UserId),
+					UpdateExpression: aws.String("ADD score :score SET #name = :name, updatedAt = :now"),
+					ExpressionAttributeNames: map[string]*string{
+						"#name": aws.String("name"),
+					},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+						":score": {N: aws.String(strconv.Itoa(entry.Score))},
+						":name":  {S: aws.String(to.Name)},
+						":now":   {S: aws.String(time.Now().Format(time.RFC3339))},
+					},
+				},
+			},
+			{
+				Delete: &dynamodb.Delete{
+					TableName: aws.String(LeaderboardsTableName),
+					Key:       leaderboardKey(entry.Board, entry.UserId),
+				},
+			},
+		},
+	})
+	return err
+}
+
+// tombstoneUser marks the user as merged and hands its XP, streak and
+// inventory over to the target in a single transaction. XP, freezes and
+// boosts are added to the target, so that what it earns or claims meanwhile
+// is kept. The target's streak and the merged user's XP and boosts must not
+// have changed since they were read, otherwise the merge fails and can be
+// run again.
+func (s *Store) tombstoneUser(from *User, to *User) error {
+	merged := streak.Merge(to.State, from.State)
+	xpBoosts := to.XPBoosts + from.XPBoosts
+	if xpBoosts > MaxXPBoosts {
+		xpBoosts = MaxXPBoosts
+	}
+
+	set := "SET currentStreak = :current, longestStreak = :longest"
+	add := "ADD xp :xp, streakFreezes :freezes, xpBoosts :xpBoosts"
+	values := map[string]*dynamodb.AttributeValue{
+		":xp":       {N: aws.String(strconv.Itoa(from.XP))},
+		":current":  {N: aws.String(strconv.Itoa(merged.Current))},
+		":longest":  {N: aws.String(strconv.Itoa(merged.Longest))},
+		":freezes":  {N: aws.String(strconv.Itoa(merged.Freezes - to.Freezes))},
+		":xpBoosts": {N: aws.String(strconv.Itoa(xpBoosts - to.XPBoosts))},
+	}
+	if merged.LastPracticeDate != "" {
+		set += ", lastPracticeDate = :lastPracticeDate"
+		values[":lastPracticeDate"] = &dynamodb.AttributeValue{S: aws.String(merged.LastPracticeDate)}
+	}
+	if merged.VacationFrom != "" && merged.VacationTo != "" {
+		set += ", vacationFrom = :vacationFrom, vacationTo = :vacationTo"
+		values[":vacationFrom"] = &dynamodb.AttributeValue{S: aws.String(merged.VacationFrom)}
+		values[":vacationTo"] = &dynamodb.AttributeValue{S: aws.String(merged.VacationTo)}
+	}
+	// String sets can't be empty, so they are only added if there is anything
+	if len(from.InstalledPacks) > 0 {
+		add += ", installedPacks :installedPacks"
+		values[":installedPacks"] = &dynamodb.AttributeValue{SS: aws.StringSlice(from.InstalledPacks)}
+	}
+	if len(from.MasteredCategories) > 0 {
+		add += ", masteredCategories :masteredCategories"
+		values[":masteredCategories"] = &dynamodb.AttributeValue{SS: aws.StringSlice(from.MasteredCategories)}
+	}
+	condition := "attribute_not_exists(lastPracticeDate)"
+	if to.LastPracticeDate != "" {
+		condition = "lastPracticeDate = :prevPracticeDate"
+		values[":prevPracticeDate"] = &dynamodb.AttributeValue{S: aws.String(to.LastPracticeDate)}
+	}
+
+	fromCondition := "attribute_not_exists(mergedInto) AND xp = :fromXp AND attribute_not_exists(xpBoosts)"
+	fromValues := map[string]*dynamodb.AttributeValue{
+		":mergedInto": {S: aws.String(to.UserId)},
+		":mergedAt":   {S: aws.String(time.Now().Format(time.RFC3339))},
+		":zero":       {N: aws.String("0")},
+		":fromXp":     {N: aws.String(strconv.Itoa(from.XP))},
+	}
+	if from.XPBoosts > 0 {
+		fromCondition = "attribute_not_exists(mergedInto) AND xp = :fromXp AND xpBoosts = :fromBoosts"
+		fromValues[":fromBoosts"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(from.XPBoosts))}
+	}
+
+	_, err := s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(UsersTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"userId": {S: aws.String(to.UserId)},
+					},
+					ConditionExpression:       aws.String(condition),
+					UpdateExpression:          aws.String(add + " " + set),
+					ExpressionAttributeValues: values,
+				},
+			},
+			{
+				Update: &dynamodb.Update{
+					TableName: aws.String(UsersTableName),
+					Key: map[string]*dynamodb.AttributeValue{
+						"userId": {S: aws.String(from.UserId)},
+					},
+					ConditionExpression:       aws.String(fromCondition),
+					UpdateExpression:          aws.String("SET mergedInto = :mergedInto, mergedAt = :mergedAt, xp = :zero REMOVE xpBoosts"),
+					ExpressionAttributeValues: fromValues,
+				},
+			},
+		},
+	})
+	return err
+}
@@ -0,0 +1,185 @@
+package store_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+const duplicateId = "fixture-duplicate"
+
+// mergeState is what MergeUsers leaves in the tables, with the times it
+// takes from the clock zeroed.
+type mergeState struct {
+	Users          []store.User             `json:"users"`
+	WordStatistics []store.WordStatistics   `json:"wordStatistics"`
+	Attempts       []store.Attempt          `json:"attempts"`
+	Devices        []store.Device           `json:"devices"`
+	Gifts          []store.Gift             `json:"gifts"`
+	Certifications []store.Certification    `json:"certifications"`
+	Contacts       []store.Contact          `json:"contacts"`
+	Leaderboards   []store.LeaderboardEntry `json:"leaderboards"`
+}
+
+// TestMergeUsers merges a duplicate account with data in every table into
+// the fixture user, overlapping where the two can collide.
+func TestMergeUsers(t *testing.T) {
+	db := newFakeDB()
+	seed(t, db, store.UsersTableName,
+		store.User{
+			UserId: fixtures.UserId, Email: "user@example.com", Name: "Fixture", XP: 120, XPBoosts: 4,
+			InstalledPacks: []string{"hp-2023"}, MasteredCategories: []string{"verb"},
+			State: streak.State{Current: 3, Longest: 10, LastPracticeDate: "2024-03-13", Freezes: 1},
+		},
+		store.User{
+			UserId: duplicateId, Email: "user@example.com", Name: "Duplicate", XP: 80, XPBoosts: 3,
+			InstalledPacks: []string{"hp-2023", "hp-2024"}, MasteredCategories: []string{"adjektiv"},
+			State: streak.State{Current: 5, Longest: 5, LastPracticeDate: "2024-03-15", Freezes: 2, VacationFrom: "2024-04-01", VacationTo: "2024-04-07"},
+		},
+		store.User{UserId: "fixture-friend", Email: "friend@example.com", Name: "Friend"},
+	)
+
+	stats := fixtures.WordStatistics()
+	target, moved := stats["arkaisk"], stats["banal"]
+	shared := target
+	shared.UserId = duplicateId
+	shared.Attempts, shared.Success = 2, 2
+	shared.IntervalDays = 4
+	shared.LastPracticedAt = fixtures.Now.Format(time.RFC3339)
+	shared.NextReviewAt = fixtures.Now.AddDate(0, 0, 4).Format(time.RFC3339)
+	shared.FirstSeenAt = fixtures.Now.AddDate(0, -6, 0).Format(time.RFC3339)
+	moved.UserId = duplicateId
+	seed(t, db, store.WordStatsTableName, target, shared, moved)
+
+	seed(t, db, store.AttemptsTableName,
+		store.Attempt{UserId: fixtures.UserId, AttemptId: "2024-03-13T10:00:00Z#a", Word: target.Word, IsCorrect: true, AnsweredAt: "2024-03-13T10:00:00Z"},
+		store.Attempt{UserId: duplicateId, AttemptId: "2024-03-15T10:00:00Z#b", Word: shared.Word, IsCorrect: true, AnsweredAt: "2024-03-15T10:00:00Z"},
+	)
+	seed(t, db, store.DevicesTableName,
+		store.Device{UserId: fixtures.UserId, DeviceId: "phone", Platform: "ios", LastSeenAt: "2024-03-13T10:00:00Z"},
+		store.Device{UserId: duplicateId, DeviceId: "phone", Platform: "ios", LastSeenAt: "2024-03-15T10:00:00Z"},
+		store.Device{UserId: duplicateId, DeviceId: "tablet", Platform: "android", LastSeenAt: "2024-03-15T10:00:00Z"},
+	)
+	seed(t, db, store.GiftsTableName,
+		store.Gift{UserId: duplicateId, GiftId: "2024-03-14#fixture-friend#xpBoost", FromUserId: "fixture-friend", Kind: store.GiftXPBoost, Status: store.GiftPending},
+	)
+	seed(t, db, store.CertificationsTableName,
+		store.Certification{UserId: duplicateId, CertificationId: "cert-1", Category: "adjektiv", Status: store.CertificationPassed, Score: 0.9},
+	)
+	seed(t, db, store.ContactsTableName,
+		store.Contact{UserId: duplicateId, ContactUserId: "fixture-friend", Name: "Friend", Status: store.ContactAccepted},
+		store.Contact{UserId: "fixture-friend", ContactUserId: duplicateId, Name: "Duplicate", Status: store.ContactAccepted},
+		store.Contact{UserId: duplicateId, ContactUserId: fixtures.UserId, Name: "Fixture", Status: store.ContactOutgoing},
+		store.Contact{UserId: fixtures.UserId, ContactUserId: duplicateId, Name: "Duplicate", Status: store.ContactIncoming},
+	)
+	seed(t, db, store.LeaderboardsTableName,
+		store.LeaderboardEntry{Board: store.BoardKey(), UserId: fixtures.UserId, Name: "Fixture", Score: 120},
+		store.LeaderboardEntry{Board: store.BoardKey(), UserId: duplicateId, Name: "Duplicate", Score: 80},
+		store.LeaderboardEntry{Board: store.BoardKey(store.ScopeCategory, "adjektiv"), UserId: duplicateId, Name: "Duplicate", Score: 30},
+	)
+
+	s := store.New(db)
+	result, err := s.MergeUsers(duplicateId, fixtures.UserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := dump(t, db)
+	fixtures.Golden(t, "merge", struct {
+		Result *store.MergeResult `json:"result"`
+		State  mergeState         `json:"state"`
+	}{result, state})
+
+	// An interrupted merge is finished by running it again, so running a
+	// completed one again must not change anything
+	if _, err := s.MergeUsers(duplicateId, fixtures.UserId); err != nil {
+		t.Fatal(err)
+	}
+	if again := dump(t, db); !reflect.DeepEqual(again, state) {
+		t.Errorf("merging again changed the tables:\ngot  %+v\nwant %+v", again, state)
+	}
+}
+
+// TestMergeUsersOrphanContact merges a user holding a contact whose mirror
+// is gone. The contact is dropped and the merge carries on.
+func TestMergeUsersOrphanContact(t *testing.T) {
+	db := newFakeDB()
+	seed(t, db, store.UsersTableName,
+		store.User{UserId: fixtures.UserId, Name: "Fixture"},
+		store.User{UserId: duplicateId, Name: "Duplicate", XP: 80},
+	)
+	seed(t, db, store.ContactsTableName,
+		store.Contact{UserId: duplicateId, ContactUserId: "fixture-friend", Name: "Friend", Status: store.ContactAccepted},
+	)
+
+	result, err := store.New(db).MergeUsers(duplicateId, fixtures.UserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state := dump(t, db); len(state.Contacts) != 0 {
+		t.Errorf("contacts left: %+v", state.Contacts)
+	}
+	if result.MergedXP != 80 {
+		t.Errorf("merged %d xp, want 80", result.MergedXP)
+	}
+}
+
+func TestMergeUsersAcrossResidencies(t *testing.T) {
+	db := newFakeDB()
+	seed(t, db, store.UsersTableName,
+		store.User{UserId: fixtures.UserId, Name: "Fixture", ResidencyRegion: store.DefaultResidency},
+		store.User{UserId: duplicateId, Name: "Duplicate", ResidencyRegion: store.ResidencyUS},
+	)
+
+	if _, err := store.New(db).MergeUsers(duplicateId, fixtures.UserId); err == nil {
+		t.Fatal("merged users of different residencies")
+	}
+	for _, user := range dump(t, db).Users {
+		if user.MergedInto != "" {
+			t.Errorf("user %s merged into %s", user.UserId, user.MergedInto)
+		}
+	}
+}
+
+func seed(t *testing.T, db *fakeDB, table string, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		it, err := dynamodbattribute.MarshalMap(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.put(table, it)
+	}
+}
+
+func dump(t *testing.T, db *fakeDB) mergeState {
+	t.Helper()
+	var state mergeState
+	tables := map[string]interface{}{
+		store.UsersTableName:          &state.Users,
+		store.WordStatsTableName:      &state.WordStatistics,
+		store.AttemptsTableName:       &state.Attempts,
+		store.DevicesTableName:        &state.Devices,
+		store.GiftsTableName:          &state.Gifts,
+		store.CertificationsTableName: &state.Certifications,
+		store.ContactsTableName:       &state.Contacts,
+		store.LeaderboardsTableName:   &state.Leaderboards,
+	}
+	for table, rows := range tables {
+		if err := dynamodbattribute.UnmarshalListOfMaps(db.items(table), rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range state.Users {
+		state.Users[i].MergedAt = ""
+	}
+	for i := range state.Leaderboards {
+		state.Leaderboards[i].UpdatedAt = ""
+	}
+	return state
+}
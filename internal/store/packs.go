@@ -0,0 +1,101 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const PacksTableName = "Packs"
+
+var ErrPackNotFound = errors.New("pack not found")
+
+// Pack is a curated set of words (an official deck) users can install.
+type Pack struct {
+	PackId      string   `json:"packId"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Difficulty  string   `json:"difficulty"`
+	Language    string   `json:"language"`
+	Words       []string `json:"words"`
+	Size        int      `json:"size"`
+}
+
+func (s *Store) ListPacks() ([]Pack, error) {
+	var packs []Pack
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String(PacksTableName),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []Pack
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		packs = append(packs, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan packs: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal packs: %w", unmarshalErr)
+	}
+	return packs, nil
+}
+
+func (s *Store) GetPack(packId string) (*Pack, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(PacksTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"packId": {S: aws.String(packId)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrPackNotFound
+	}
+
+	var pack Pack
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &pack); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pack: %w", err)
+	}
+	return &pack, nil
+}
+
+// PutPack creates or replaces a pack. Size is derived from the word list.
+func (s *Store) PutPack(pack Pack) error {
+	pack.Size = len(pack.Words)
+	item, err := dynamodbattribute.MarshalMap(pack)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(PacksTableName),
+		Item:      item,
+	})
+	return err
+}
+
+// SetPackInstalled subscribes the user to a pack or removes the subscription.
+func (s *Store) SetPackInstalled(userId string, packId string, installed bool) error {
+	updateExpression := "DELETE installedPacks :pack"
+	if installed {
+		updateExpression = "ADD installedPacks :pack"
+	}
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		UpdateExpression: aws.String(updateExpression),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pack": {SS: []*string{aws.String(packId)}},
+		},
+	})
+	return err
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// SuccessRatio derives the successRatio of a WordStatistics row. Rows written
+// with an older formula are brought up to date by replaying the attempt log,
+// see package projection.
+func SuccessRatio(success int, attempts int) float32 {
+	if attempts <= 0 {
+		return 0
+	}
+	return float32(success) / float32(attempts)
+}
+
+// ScanUserIdSegment lists the userIds of one segment of a parallel scan of
+// the Users table, continuing after start if it is set. page is called for
+// every scanned page with the last userId of the page, or "" once the segment
+// is done, so the caller can checkpoint it; returning an error from it stops
+// the scan.
+func (s *Store) ScanUserIdSegment(segment int, totalSegments int, start string, page func(userIds []string, cursor string) error) error {
+	input := &dynamodb.ScanInput{
+		TableName:            aws.String(UsersTableName),
+		ProjectionExpression: aws.String("userId"),
+		Segment:              aws.Int64(int64(segment)),
+		TotalSegments:        aws.Int64(int64(totalSegments)),
+	}
+	if start != "" {
+		input.ExclusiveStartKey = map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(start)},
+		}
+	}
+
+	for {
+		result, err := s.db.Scan(input)
+		if err != nil {
+			return fmt.Errorf("failed to scan users: %w", err)
+		}
+		userIds := make([]string, 0, len(result.Items))
+		for _, item := range result.Items {
+			userIds = append(userIds, aws.StringValue(item["userId"].S))
+		}
+
+		cursor := ""
+		if len(result.LastEvaluatedKey) > 0 {
+			cursor = aws.StringValue(result.LastEvaluatedKey["userId"].S)
+		}
+		if err := page(userIds, cursor); err != nil {
+			return err
+		}
+		if cursor == "" {
+			return nil
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+}
+
+// RecomputeSuccessRatio rewrites the successRatio of a row from its counters.
+// It is used for rows counting attempts from before the attempt log existed,
+// which can't be replayed. It reports whether the row was written; it isn't
+// if the ratio is current or the row changed since it was read.
+func (s *Store) RecomputeSuccessRatio(stat WordStatistics) (bool, error) {
+	// Ratios are stored with six decimals, compare them the same way
+	successRatio := fmt.Sprintf("%f", SuccessRatio(stat.Success, stat.Attempts))
+	if successRatio == fmt.Sprintf("%f", stat.SuccessRatio) {
+		return false, nil
+	}
+
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:           aws.String(WordStatsTableName),
+		Key:                 wordStatsKey(stat.UserId, stat.Word),
+		ConditionExpression: aws.String("attempts = :attempts"),
+		UpdateExpression:    aws.String("SET successRatio = :successRatio"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":attempts":     {N: aws.String(fmt.Sprintf("%d", stat.Attempts))},
+			":successRatio": {N: aws.String(successRatio)},
+		},
+	})
+	if isConditionalCheckFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
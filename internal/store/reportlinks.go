@@ -0,0 +1,148 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+const (
+	ReportLinksTableName = "ReportLinks"
+
+	reportLinksTokenIndex = "token-index"
+)
+
+var ErrReportLinkNotFound = errors.New("report link not found")
+
+// ReportLink shares a stored progress report. The reports lambda serves the
+// report to anyone holding the token until ExpiresAt or until the link is
+// deleted, which revokes it. The table's TTL attribute is expiresAt.
+type ReportLink struct {
+	UserId string `json:"userId"`
+	Token  string `json:"token"`
+	// Key is the report's key in the reports bucket, see report.Archive.
+	Key         string `json:"key"`
+	ContentType string `json:"contentType"`
+	Month       string `json:"month"`
+	// CreatedBy is the userId of whoever generated the report, the user or
+	// a teacher of the user.
+	CreatedBy string `json:"createdBy"`
+	CreatedAt string `json:"createdAt"`
+	// ExpiresAt is in Unix seconds.
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Expired reports whether the link can't be used anymore at now. DynamoDB
+// only deletes expired rows eventually.
+func (l *ReportLink) Expired(now time.Time) bool {
+	return now.Unix() >= l.ExpiresAt
+}
+
+// PutReportLink stores the link under a new random token and returns it.
+func (s *Store) PutReportLink(link ReportLink) (*ReportLink, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	link.Token = base64.RawURLEncoding.EncodeToString(token)
+	item, err := dynamodbattribute.MarshalMap(link)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(ReportLinksTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindReportLink returns the link with the given token. It fails with
+// ErrReportLinkNotFound if there is none or it expired.
+func (s *Store) FindReportLink(token string, now time.Time) (*ReportLink, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(ReportLinksTableName),
+		IndexName:              aws.String(reportLinksTokenIndex),
+		KeyConditionExpression: aws.String("#token = :token"),
+		ExpressionAttributeNames: map[string]*string{
+			"#token": aws.String("token"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":token": {S: aws.String(token)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrReportLinkNotFound
+	}
+
+	var link ReportLink
+	if err := dynamodbattribute.UnmarshalMap(result.Items[0], &link); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report link: %w", err)
+	}
+	if link.Expired(now) {
+		return nil, ErrReportLinkNotFound
+	}
+	return &link, nil
+}
+
+// ListReportLinks returns the links to the user's reports, expired ones
+// included.
+func (s *Store) ListReportLinks(userId string) ([]ReportLink, error) {
+	items, err := s.queryUserItems(ReportLinksTableName, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query report links: %w", err)
+	}
+	var links []ReportLink
+	if err := dynamodbattribute.UnmarshalListOfMaps(items, &links); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report links: %w", err)
+	}
+	return links, nil
+}
+
+// GetReportLink returns the user's link with the given token, expired or
+// not. It fails with ErrReportLinkNotFound if there is none.
+func (s *Store) GetReportLink(userId string, token string) (*ReportLink, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(ReportLinksTableName),
+		Key:       reportLinkKey(userId, token),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrReportLinkNotFound
+	}
+
+	var link ReportLink
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &link); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal report link: %w", err)
+	}
+	return &link, nil
+}
+
+// DeleteReportLink revokes a link of the user.
+func (s *Store) DeleteReportLink(userId string, token string) error {
+	_, err := s.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(ReportLinksTableName),
+		Key:       reportLinkKey(userId, token),
+	})
+	return err
+}
+
+func reportLinkKey(userId string, token string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId": {S: aws.String(userId)},
+		"token":  {S: aws.String(token)},
+	}
+}
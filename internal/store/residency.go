@@ -0,0 +1,79 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Data residencies, i.e. where a user's data may be stored
+const (
+	ResidencyEU = "eu"
+	ResidencyUS = "us"
+
+	// DefaultResidency holds the users that signed up before residency was
+	// recorded, whose data has always lived in Region.
+	DefaultResidency = ResidencyEU
+)
+
+// Regions maps each residency to the AWS region holding its tables.
+var Regions = map[string]string{
+	ResidencyEU: Region,
+	ResidencyUS: "us-east-1",
+}
+
+var ErrWrongResidency = errors.New("user data resides in another region")
+
+// usCountries are the countries whose users are kept in the US region. All
+// other users stay in the EU.
+var usCountries = map[string]bool{
+	"US": true,
+	"CA": true,
+	"MX": true,
+}
+
+// ResidencyForCountry picks the residency for an ISO 3166-1 alpha-2 country
+// code, such as "US". Unknown countries get DefaultResidency.
+func ResidencyForCountry(country string) string {
+	if usCountries[strings.ToUpper(country)] {
+		return ResidencyUS
+	}
+	return DefaultResidency
+}
+
+// NewForResidency creates a Store on the tables of the residency's region.
+// It refuses users of any other residency with ErrWrongResidency.
+func NewForResidency(residency string) (*Store, error) {
+	region, exists := Regions[residency]
+	if !exists {
+		return nil, fmt.Errorf("unknown residency %q", residency)
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := New(dynamodb.New(sess))
+	s.residency = residency
+	return s, nil
+}
+
+// Residency returns the residency the store is restricted to, or "" if it
+// serves every user.
+func (s *Store) Residency() string {
+	return s.residency
+}
+
+// CheckResidency fails with ErrWrongResidency if data of the given residency
+// must not be kept in this store.
+func (s *Store) CheckResidency(residency string) error {
+	if s.residency != "" && residency != s.residency {
+		return ErrWrongResidency
+	}
+	return nil
+}
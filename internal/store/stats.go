@@ -0,0 +1,108 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// wordStatsRatioIndex sorts a user's WordStatistics by successRatio
+const wordStatsRatioIndex = "userId-successRatio-index"
+
+type WordStatistics struct {
+	UserId       string  `json:"userId"`
+	Word         string  `json:"word"`
+	Attempts     int     `json:"attempts"`
+	Success      int     `json:"success"`
+	SuccessRatio float32 `json:"successRatio"`
+
+	// Review schedule, see package srs
+	IntervalDays    int    `json:"intervalDays"`
+	NextReviewAt    string `json:"nextReviewAt,omitempty"`
+	LastPracticedAt string `json:"lastPracticedAt,omitempty"`
+
+	// FirstSeenAt is when the user first answered the word. Rows from before
+	// it was tracked don't have it.
+	FirstSeenAt string `json:"firstSeenAt,omitempty"`
+}
+
+// ListWordStatistics returns every WordStatistics row stored for the user.
+func (s *Store) ListWordStatistics(userId string) ([]WordStatistics, error) {
+	var stats []WordStatistics
+	var unmarshalErr error
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(WordStatsTableName),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+	}
+
+	err := s.db.QueryPages(input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		var items []WordStatistics
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		stats = append(stats, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query word statistics: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal word statistics: %w", unmarshalErr)
+	}
+	return stats, nil
+}
+
+// WeakestWordStatistics returns up to limit of the user's statistics, lowest
+// success ratio first.
+func (s *Store) WeakestWordStatistics(userId string, limit int) ([]WordStatistics, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(WordStatsTableName),
+		IndexName:              aws.String(wordStatsRatioIndex),
+		KeyConditionExpression: aws.String("userId = :userId"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":userId": {S: aws.String(userId)},
+		},
+		ScanIndexForward: aws.Bool(true), // Lowest ratio first
+		Limit:            aws.Int64(int64(limit)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weakest words: %w", err)
+	}
+
+	var stats []WordStatistics
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &stats); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal word statistics: %w", err)
+	}
+	return stats, nil
+}
+
+func (s *Store) getWordStatistics(userId string, word string) (*WordStatistics, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(WordStatsTableName),
+		Key:       wordStatsKey(userId, word),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+
+	var stats WordStatistics
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &stats); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal word statistics: %w", err)
+	}
+	return &stats, nil
+}
+
+func wordStatsKey(userId string, word string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"userId": {S: aws.String(userId)},
+		"word":   {S: aws.String(word)},
+	}
+}
@@ -0,0 +1,47 @@
+// Package store holds the DynamoDB access shared between the lambdas and the
+// operator tooling.
+package store
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	Region = "eu-north-1"
+
+	UsersTableName     = "Users"
+	WordsTableName     = "Words"
+	WordStatsTableName = "WordStatistics"
+
+	usersEmailIndex = "email-userId-index"
+)
+
+type Store struct {
+	db dynamodbiface.DynamoDBAPI
+	// residency restricts the store to users of one residency, see
+	// NewForResidency.
+	residency string
+}
+
+func New(db dynamodbiface.DynamoDBAPI) *Store {
+	return &Store{db: db}
+}
+
+// NewFromSession creates a Store on the tables of the DATA_RESIDENCY
+// residency, DefaultResidency if unset, see NewForResidency.
+func NewFromSession() (*Store, error) {
+	residency := os.Getenv("DATA_RESIDENCY")
+	if residency == "" {
+		residency = DefaultResidency
+	}
+	return NewForResidency(residency)
+}
+
+func isConditionalCheckFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
@@ -0,0 +1,184 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/google/uuid"
+)
+
+const SuggestionsTableName = "Suggestions"
+
+const (
+	SuggestionPending  = "pending"
+	SuggestionApproved = "approved"
+	SuggestionRejected = "rejected"
+)
+
+var (
+	ErrSuggestionNotFound = errors.New("suggestion not found")
+	ErrSuggestionReviewed = errors.New("suggestion already reviewed")
+)
+
+// Suggestion is a word proposed by a user. It only reaches the Words table
+// once an operator approves it.
+type Suggestion struct {
+	SuggestionId string `json:"suggestionId"`
+	UserId       string `json:"userId"`
+	Word         Word   `json:"word"`
+	Status       string `json:"status"`
+	CreatedAt    string `json:"createdAt"`
+	ReviewedBy   string `json:"reviewedBy,omitempty"`
+	ReviewedAt   string `json:"reviewedAt,omitempty"`
+}
+
+// PutSuggestion stores a pending suggestion of word by the user.
+func (s *Store) PutSuggestion(userId string, word Word, now time.Time) (*Suggestion, error) {
+	suggestion := Suggestion{
+		SuggestionId: uuid.New().String(),
+		UserId:       userId,
+		Word:         word,
+		Status:       SuggestionPending,
+		CreatedAt:    now.Format(time.RFC3339),
+	}
+	item, err := dynamodbattribute.MarshalMap(suggestion)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(SuggestionsTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &suggestion, nil
+}
+
+func (s *Store) GetSuggestion(suggestionId string) (*Suggestion, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(SuggestionsTableName),
+		Key:       suggestionKey(suggestionId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrSuggestionNotFound
+	}
+
+	var suggestion Suggestion
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &suggestion); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal suggestion: %w", err)
+	}
+	return &suggestion, nil
+}
+
+// ListSuggestions returns the suggestions with the given status. It scans
+// the table, which only operators do.
+func (s *Store) ListSuggestions(status string) ([]Suggestion, error) {
+	var suggestions []Suggestion
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName:        aws.String(SuggestionsTableName),
+		FilterExpression: aws.String("#status = :status"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":status": {S: aws.String(status)},
+		},
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []Suggestion
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		suggestions = append(suggestions, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan suggestions: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal suggestions: %w", unmarshalErr)
+	}
+	return suggestions, nil
+}
+
+// RejectSuggestion marks a pending suggestion as rejected. It fails with
+// ErrSuggestionReviewed if the suggestion was already reviewed.
+func (s *Store) RejectSuggestion(suggestionId string, reviewer string, now time.Time) error {
+	_, err := s.db.UpdateItem(reviewUpdate(suggestionId, SuggestionRejected, reviewer, now))
+	if isConditionalCheckFailed(err) {
+		return ErrSuggestionReviewed
+	}
+	return err
+}
+
+// ApproveSuggestion stores the suggested word and marks the suggestion as
+// approved in one transaction. Check the word with package dedupe first. It
+// fails with ErrSuggestionReviewed if the suggestion was already reviewed.
+func (s *Store) ApproveSuggestion(suggestion *Suggestion, reviewer string, now time.Time) error {
+	item, err := dynamodbattribute.MarshalMap(suggestion.Word)
+	if err != nil {
+		return fmt.Errorf("failed to marshal word: %w", err)
+	}
+	update := reviewUpdate(suggestion.SuggestionId, SuggestionApproved, reviewer, now)
+	_, err = s.db.TransactWriteItems(&dynamodb.TransactWriteItemsInput{
+		TransactItems: []*dynamodb.TransactWriteItem{
+			{
+				Update: &dynamodb.Update{
+					TableName:                 update.TableName,
+					Key:                       update.Key,
+					ConditionExpression:       update.ConditionExpression,
+					UpdateExpression:          update.UpdateExpression,
+					ExpressionAttributeNames:  update.ExpressionAttributeNames,
+					ExpressionAttributeValues: update.ExpressionAttributeValues,
+				},
+			},
+			{
+				Put: &dynamodb.Put{
+					TableName: aws.String(WordsTableName),
+					Item:      item,
+				},
+			},
+		},
+	})
+	if aerr, ok := err.(*dynamodb.TransactionCanceledException); ok {
+		for _, reason := range aerr.CancellationReasons {
+			if reason.Code != nil && *reason.Code == "ConditionalCheckFailed" {
+				return ErrSuggestionReviewed
+			}
+		}
+	}
+	return err
+}
+
+// reviewUpdate records the decision on a suggestion, if it is still pending.
+func reviewUpdate(suggestionId string, status string, reviewer string, now time.Time) *dynamodb.UpdateItemInput {
+	return &dynamodb.UpdateItemInput{
+		TableName:           aws.String(SuggestionsTableName),
+		Key:                 suggestionKey(suggestionId),
+		ConditionExpression: aws.String("#status = :pending"),
+		UpdateExpression:    aws.String("SET #status = :status, reviewedBy = :reviewer, reviewedAt = :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":pending":  {S: aws.String(SuggestionPending)},
+			":status":   {S: aws.String(status)},
+			":reviewer": {S: aws.String(reviewer)},
+			":now":      {S: aws.String(now.Format(time.RFC3339))},
+		},
+	}
+}
+
+func suggestionKey(suggestionId string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"suggestionId": {S: aws.String(suggestionId)},
+	}
+}
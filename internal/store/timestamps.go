@@ -0,0 +1,31 @@
+package store
+
+import "time"
+
+// idTimeLayout formats the time that starts a sort key, such as an
+// attemptId, in UTC. Unlike RFC3339Nano it keeps trailing zeros, so the keys
+// sort chronologically as strings.
+const idTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// CompareTimestamps compares two stored RFC3339 timestamps by the instant
+// they denote and returns -1, 0 or +1. Timestamps written with different
+// offsets or precision don't sort as strings, so they are never compared as
+// such. An empty or invalid timestamp is before any valid one, e.g. a word
+// never scheduled for review is due first.
+func CompareTimestamps(a string, b string) int {
+	at, aErr := time.Parse(time.RFC3339, a)
+	bt, bErr := time.Parse(time.RFC3339, b)
+	switch {
+	case aErr != nil && bErr != nil:
+		return 0
+	case aErr != nil:
+		return -1
+	case bErr != nil:
+		return 1
+	case at.Before(bt):
+		return -1
+	case at.After(bt):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+// UsageTableName holds one row per user and period with an atomic counter
+// per counted metric, see package ratelimit. The table's TTL attribute is
+// expiresAt.
+const UsageTableName = "Usage"
+
+// AddUsage adds n to the user's counter of the period and returns the new
+// value. The row is deleted by DynamoDB some time after expiresAt.
+func (s *Store) AddUsage(userId string, period string, counter string, n int64, expiresAt time.Time) (int64, error) {
+	result, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsageTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+			"period": {S: aws.String(period)},
+		},
+		UpdateExpression: aws.String("ADD #counter :n SET expiresAt = :expiresAt"),
+		ExpressionAttributeNames: map[string]*string{
+			"#counter": aws.String(counter),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n":         {N: aws.String(strconv.FormatInt(n, 10))},
+			":expiresAt": {N: aws.String(strconv.FormatInt(expiresAt.Unix(), 10))},
+		},
+		ReturnValues: aws.String("UPDATED_NEW"),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var updated map[string]int64
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &updated); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal usage: %w", err)
+	}
+	return updated[counter], nil
+}
+
+// GetUsage returns the user's counters of the period, keyed by counter.
+// Counters never added to are missing.
+func (s *Store) GetUsage(userId string, period string) (map[string]int64, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(UsageTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+			"period": {S: aws.String(period)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]int64)
+	for name, value := range result.Item {
+		if value.N == nil || name == "expiresAt" {
+			continue
+		}
+		count, err := strconv.ParseInt(*value.N, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse usage %s: %w", name, err)
+		}
+		counters[name] = count
+	}
+	return counters, nil
+}
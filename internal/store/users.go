@@ -0,0 +1,337 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/streak"
+)
+
+const RoleAdmin = "admin"
+
+var (
+	ErrUserNotFound = errors.New("No user found")
+	ErrUserDisabled = errors.New("User is disabled")
+
+	ErrStreakConflict = errors.New("streak was updated concurrently")
+	ErrVacationInPast = errors.New("vacation starts before the last practice")
+)
+
+type User struct {
+	UserId     string `json:"userId"`
+	Email      string `json:"email"`
+	CreatedAt  string `json:"createdAt"`
+	Name       string `json:"name"`
+	Provider   string `json:"provider"`
+	Role       string `json:"role,omitempty"`
+	Disabled   bool   `json:"disabled,omitempty"`
+	XP         int    `json:"xp"`
+	MergedInto string `json:"mergedInto,omitempty"`
+	MergedAt   string `json:"mergedAt,omitempty"`
+	// MasteredCategories are the categories the user has certified in.
+	MasteredCategories []string `json:"masteredCategories,omitempty" dynamodbav:"masteredCategories,stringset,omitempty"`
+	// InstalledPacks are the packs the user practices from, see Pack.
+	InstalledPacks []string `json:"installedPacks,omitempty" dynamodbav:"installedPacks,stringset,omitempty"`
+	// Groups are the classroom groups the user is a member of, see Group.
+	Groups []string `json:"groups,omitempty" dynamodbav:"groups,stringset,omitempty"`
+	// XPBoosts are claimed gifts, each doubles the XP of one results upload.
+	XPBoosts int `json:"xpBoosts,omitempty"`
+	// ResidencyRegion is where the user's data may be stored, set at signup.
+	ResidencyRegion string `json:"residencyRegion,omitempty"`
+
+	streak.State
+}
+
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// Residency returns the user's residency, DefaultResidency if none was
+// recorded.
+func (u *User) Residency() string {
+	if u.ResidencyRegion == "" {
+		return DefaultResidency
+	}
+	return u.ResidencyRegion
+}
+
+func (u *User) HasMastered(category string) bool {
+	for _, mastered := range u.MasteredCategories {
+		if mastered == category {
+			return true
+		}
+	}
+	return false
+}
+
+// FindUserByEmail returns the active user registered with the given email.
+// Users that have been merged into another account are skipped, so a
+// duplicate created by the signup race resolves to the surviving userId.
+// ErrUserDisabled is returned if the account has been disabled, and
+// ErrWrongResidency if its data belongs in another region.
+func (s *Store) FindUserByEmail(email string) (*User, error) {
+	users, err := s.FindUsersByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.MergedInto != "" {
+			continue
+		}
+		if user.Disabled {
+			return nil, ErrUserDisabled
+		}
+		if err := s.CheckResidency(user.Residency()); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+	return nil, ErrUserNotFound
+}
+
+// FindUsersByEmail returns every user registered with the given email,
+// including merged and disabled ones.
+func (s *Store) FindUsersByEmail(email string) ([]User, error) {
+	result, err := s.db.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(UsersTableName),
+		IndexName:              aws.String(usersEmailIndex),
+		KeyConditionExpression: aws.String("email = :email"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":email": {
+				S: aws.String(email),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := dynamodbattribute.UnmarshalListOfMaps(result.Items, &users); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *Store) GetUser(userId string) (*User, error) {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Item == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var user User
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &user); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	if err := s.CheckResidency(user.Residency()); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers returns all users, including merged and disabled ones.
+func (s *Store) ListUsers() ([]User, error) {
+	var users []User
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String(UsersTableName),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []User
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		users = append(users, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal users: %w", unmarshalErr)
+	}
+	return users, nil
+}
+
+func (s *Store) SetRole(userId string, role string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		ConditionExpression: aws.String("attribute_exists(userId)"),
+		UpdateExpression:    aws.String("REMOVE #role"),
+		ExpressionAttributeNames: map[string]*string{
+			"#role": aws.String("role"),
+		},
+	}
+	if role != "" {
+		input.UpdateExpression = aws.String("SET #role = :role")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":role": {S: aws.String(role)},
+		}
+	}
+	_, err := s.db.UpdateItem(input)
+	return err
+}
+
+func (s *Store) SetDisabled(userId string, disabled bool) error {
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		ConditionExpression: aws.String("attribute_exists(userId)"),
+		UpdateExpression:    aws.String("SET disabled = :disabled"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":disabled": {BOOL: aws.Bool(disabled)},
+		},
+	})
+	return err
+}
+
+// CheckActive reads the user with a consistent read and returns
+// ErrUserDisabled if it was disabled, or ErrUserNotFound if it doesn't exist
+// or was merged. Callers that cache the userId of an email check it on every
+// request, so disabling takes effect at once.
+func (s *Store) CheckActive(userId string) error {
+	result, err := s.db.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		ProjectionExpression: aws.String("userId, disabled, mergedInto"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	var user User
+	if err := dynamodbattribute.UnmarshalMap(result.Item, &user); err != nil {
+		return fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	switch {
+	case result.Item == nil || user.MergedInto != "":
+		return ErrUserNotFound
+	case user.Disabled:
+		return ErrUserDisabled
+	}
+	return nil
+}
+
+// AddXP adds xp to the user's total and returns the new total.
+func (s *Store) AddXP(userId string, xp int) (int, error) {
+	result, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		UpdateExpression: aws.String("ADD xp :xp"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":xp": {N: aws.String(strconv.Itoa(xp))},
+		},
+		ReturnValues: aws.String("UPDATED_NEW"),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var updated struct {
+		XP int `json:"xp"`
+	}
+	if err := dynamodbattribute.UnmarshalMap(result.Attributes, &updated); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal xp: %w", err)
+	}
+	return updated.XP, nil
+}
+
+// SaveStreak stores the streak computed from prev. It fails with
+// ErrStreakConflict if another request recorded practice in the meantime.
+// Freezes are added as the difference between next and prev, so freezes
+// granted meanwhile by a gift or an operator are kept. Such a grant racing a
+// milestone can leave the user holding one over streak.MaxFreezes.
+func (s *Store) SaveStreak(userId string, prev streak.State, next streak.State) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		UpdateExpression: aws.String("SET currentStreak = :current, longestStreak = :longest, " +
+			"lastPracticeDate = :lastPracticeDate"),
+		ConditionExpression: aws.String("attribute_not_exists(lastPracticeDate)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":current":          {N: aws.String(strconv.Itoa(next.Current))},
+			":longest":          {N: aws.String(strconv.Itoa(next.Longest))},
+			":lastPracticeDate": {S: aws.String(next.LastPracticeDate)},
+		},
+	}
+	if delta := next.Freezes - prev.Freezes; delta != 0 {
+		input.UpdateExpression = aws.String(*input.UpdateExpression + " ADD streakFreezes :delta")
+		input.ExpressionAttributeValues[":delta"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(delta))}
+	}
+	if prev.LastPracticeDate != "" {
+		input.ConditionExpression = aws.String("lastPracticeDate = :prevPracticeDate")
+		input.ExpressionAttributeValues[":prevPracticeDate"] = &dynamodb.AttributeValue{S: aws.String(prev.LastPracticeDate)}
+	}
+
+	_, err := s.db.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrStreakConflict
+	}
+	return err
+}
+
+// SetVacation sets the vacation range (inclusive, YYYY-MM-DD) during which
+// missed days don't break the streak. Empty dates clear it. A range starting
+// before the last practice day is refused with ErrVacationInPast, it would
+// cover the missed days of a streak that is already broken.
+func (s *Store) SetVacation(userId string, from string, to string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		UpdateExpression: aws.String("REMOVE vacationFrom, vacationTo"),
+	}
+	if from != "" && to != "" {
+		input.UpdateExpression = aws.String("SET vacationFrom = :from, vacationTo = :to")
+		input.ConditionExpression = aws.String("attribute_not_exists(lastPracticeDate) OR lastPracticeDate <= :from")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":from": {S: aws.String(from)},
+			":to":   {S: aws.String(to)},
+		}
+	}
+	_, err := s.db.UpdateItem(input)
+	if isConditionalCheckFailed(err) {
+		return ErrVacationInPast
+	}
+	return err
+}
+
+// AddStreakFreezes gives the user count extra streak freezes.
+func (s *Store) AddStreakFreezes(userId string, count int) error {
+	_, err := s.db.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(UsersTableName),
+		Key: map[string]*dynamodb.AttributeValue{
+			"userId": {S: aws.String(userId)},
+		},
+		ConditionExpression: aws.String("attribute_exists(userId)"),
+		UpdateExpression:    aws.String("ADD streakFreezes :count"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":count": {N: aws.String(strconv.Itoa(count))},
+		},
+	})
+	return err
+}
@@ -0,0 +1,41 @@
+package store_test
+
+import (
+	"testing"
+	"time"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+// TestSaveStreakKeepsGrantedFreezes spends a freeze while another one is
+// granted between reading and saving the streak.
+func TestSaveStreakKeepsGrantedFreezes(t *testing.T) {
+	db := newFakeDB()
+	prev := streak.State{Current: 3, Longest: 3, LastPracticeDate: "2024-03-13", Freezes: 1}
+	seed(t, db, store.UsersTableName, store.User{UserId: fixtures.UserId, State: prev})
+	s := store.New(db)
+
+	update := streak.Practice(prev, time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC))
+	if update.FreezesUsed != 1 {
+		t.Fatalf("used %d freezes, want 1", update.FreezesUsed)
+	}
+	if err := s.AddStreakFreezes(fixtures.UserId, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveStreak(fixtures.UserId, prev, update.State); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.GetUser(fixtures.UserId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Current != 4 || user.Freezes != 1 {
+		t.Errorf("got %+v, want a streak of 4 and the granted freeze", user.State)
+	}
+	if err := s.SaveStreak(fixtures.UserId, prev, update.State); err != store.ErrStreakConflict {
+		t.Errorf("saving again returned %v, want ErrStreakConflict", err)
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/grading"
+)
+
+// WordsLanguage is the language of the Words table, whose letters the
+// near-duplicate checks of package dedupe keep apart.
+const WordsLanguage = "sv"
+
+type Word struct {
+	Word       string   `json:"word"`
+	Correct    string   `json:"correct"`
+	Incorrect  []string `json:"incorrect"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	// Synonyms are accepted in addition to Correct when typing the answer
+	Synonyms []string          `json:"synonyms,omitempty"`
+	Grading  *grading.Override `json:"grading,omitempty"`
+}
+
+func (s *Store) ListWords() ([]Word, error) {
+	var words []Word
+	var unmarshalErr error
+	err := s.db.ScanPages(&dynamodb.ScanInput{
+		TableName: aws.String(WordsTableName),
+	}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		var items []Word
+		if unmarshalErr = dynamodbattribute.UnmarshalListOfMaps(page.Items, &items); unmarshalErr != nil {
+			return false
+		}
+		words = append(words, items...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan words: %w", err)
+	}
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to unmarshal words: %w", unmarshalErr)
+	}
+	return words, nil
+}
+
+// BatchGetItem accepts at most 100 keys
+const maxBatchGet = 100
+
+// GetWords looks up words by name with concurrent BatchGetItem calls. Words
+// that don't exist are left out of the result, which has no particular order.
+func (s *Store) GetWords(names []string) ([]Word, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		words    []Word
+		firstErr error
+	)
+	for start := 0; start < len(names); start += maxBatchGet {
+		end := start + maxBatchGet
+		if end > len(names) {
+			end = len(names)
+		}
+		wg.Add(1)
+		go func(chunk []string) {
+			defer wg.Done()
+			found, err := s.batchGetWords(chunk)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			words = append(words, found...)
+		}(names[start:end])
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return words, nil
+}
+
+func (s *Store) batchGetWords(names []string) ([]Word, error) {
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(names))
+	for _, name := range names {
+		keys = append(keys, map[string]*dynamodb.AttributeValue{
+			"word": {S: aws.String(name)},
+		})
+	}
+
+	var words []Word
+	pending := map[string]*dynamodb.KeysAndAttributes{
+		WordsTableName: {Keys: keys},
+	}
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt*attempt) * 50 * time.Millisecond)
+		}
+		if attempt == 5 {
+			return nil, fmt.Errorf("%d words left unprocessed", len(pending[WordsTableName].Keys))
+		}
+		result, err := s.db.BatchGetItem(&dynamodb.BatchGetItemInput{RequestItems: pending})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get words: %w", err)
+		}
+		var items []Word
+		if err := dynamodbattribute.UnmarshalListOfMaps(result.Responses[WordsTableName], &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal words: %w", err)
+		}
+		words = append(words, items...)
+		pending = result.UnprocessedKeys
+	}
+	return words, nil
+}
+
+// PutWords creates or replaces words. Check them with package dedupe first,
+// near-duplicates split the statistics of a word in two.
+func (s *Store) PutWords(words []Word) error {
+	for start := 0; start < len(words); start += maxBatchWrite {
+		end := start + maxBatchWrite
+		if end > len(words) {
+			end = len(words)
+		}
+
+		var requests []*dynamodb.WriteRequest
+		for _, word := range words[start:end] {
+			item, err := dynamodbattribute.MarshalMap(word)
+			if err != nil {
+				return fmt.Errorf("failed to marshal word: %w", err)
+			}
+			requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+		}
+		if err := s.batchWrite(WordsTableName, requests); err != nil {
+			return fmt.Errorf("failed to put words: %w", err)
+		}
+	}
+	return nil
+}
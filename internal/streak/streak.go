@@ -0,0 +1,149 @@
+// Package streak computes practice streaks.
+//
+// A streak counts consecutive days (UTC) with at least one practiced word.
+// Missed days inside the user's vacation range don't break the streak, and
+// other missed days are covered by streak freezes as long as the user holds
+// enough of them.
+//
+// Freezes are earned by reaching streak milestones, every MilestoneDays
+// days. The app has no achievements yet to earn them with, so the milestone
+// rule in Practice stands in for them and should give way once achievements
+// exist. Freezes are also claimed from gifts and granted by operators.
+package streak
+
+import "time"
+
+const (
+	DateLayout = "2006-01-02"
+
+	// MilestoneDays is how many streak days earn one freeze, in place of
+	// achievements.
+	MilestoneDays = 7
+	// MaxFreezes is the most freezes a user can hold at once.
+	MaxFreezes = 2
+	// MaxVacationDays is the longest vacation that can be set.
+	MaxVacationDays = 30
+)
+
+type State struct {
+	Current          int    `json:"currentStreak"`
+	Longest          int    `json:"longestStreak"`
+	LastPracticeDate string `json:"lastPracticeDate,omitempty"`
+	Freezes          int    `json:"streakFreezes"`
+	VacationFrom     string `json:"vacationFrom,omitempty"`
+	VacationTo       string `json:"vacationTo,omitempty"`
+}
+
+type Update struct {
+	State State `json:"state"`
+	// FreezesUsed is how many freezes were spent covering missed days.
+	FreezesUsed int `json:"freezesUsed"`
+	// FreezesEarned is how many freezes the practice earned.
+	FreezesEarned int `json:"freezesEarned"`
+}
+
+// Day truncates t to its UTC calendar day.
+func Day(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// OnVacation reports whether day lies within the vacation range (inclusive).
+func (s State) OnVacation(day time.Time) bool {
+	if s.VacationFrom == "" || s.VacationTo == "" {
+		return false
+	}
+	from, err := time.Parse(DateLayout, s.VacationFrom)
+	if err != nil {
+		return false
+	}
+	to, err := time.Parse(DateLayout, s.VacationTo)
+	if err != nil {
+		return false
+	}
+	day = Day(day)
+	return !day.Before(from) && !day.After(to)
+}
+
+// Practice records practice on the day of now and returns the new state.
+func Practice(state State, now time.Time) Update {
+	today := Day(now)
+	update := Update{State: state}
+
+	last, err := time.Parse(DateLayout, state.LastPracticeDate)
+	switch {
+	case err != nil || state.Current == 0:
+		update.State.Current = 1
+	case !today.After(last):
+		// Already practiced today (or the clock went backwards)
+		return update
+	default:
+		missed := state.missedDays(last, today)
+		if missed > state.Freezes {
+			update.State.Current = 1
+		} else {
+			update.FreezesUsed = missed
+			update.State.Freezes -= missed
+			update.State.Current++
+		}
+	}
+
+	update.State.LastPracticeDate = today.Format(DateLayout)
+	if update.State.Current > update.State.Longest {
+		update.State.Longest = update.State.Current
+	}
+	if update.State.Current%MilestoneDays == 0 && update.State.Freezes < MaxFreezes {
+		update.State.Freezes++
+		update.FreezesEarned = 1
+	}
+	return update
+}
+
+// Effective returns the streak as it stands on the day of now without
+// recording practice: a streak whose missed days can't be covered by
+// vacation or freezes is reported as 0.
+func Effective(state State, now time.Time) int {
+	last, err := time.Parse(DateLayout, state.LastPracticeDate)
+	if err != nil {
+		return 0
+	}
+	if state.missedDays(last, Day(now)) > state.Freezes {
+		return 0
+	}
+	return state.Current
+}
+
+// missedDays counts the days strictly between last and today that were
+// neither practiced nor part of the vacation.
+func (s State) missedDays(last time.Time, today time.Time) int {
+	missed := 0
+	for day := last.AddDate(0, 0, 1); day.Before(today); day = day.AddDate(0, 0, 1) {
+		if !s.OnVacation(day) {
+			missed++
+		}
+	}
+	return missed
+}
+
+// Merge combines the streaks of two accounts of the same person. The streak
+// practiced most recently carries on, freezes add up to MaxFreezes and a
+// vacation set on either account is kept, a's if both have one.
+func Merge(a State, b State) State {
+	merged := a
+	if b.LastPracticeDate > a.LastPracticeDate || (b.LastPracticeDate == a.LastPracticeDate && b.Current > a.Current) {
+		merged.Current = b.Current
+		merged.LastPracticeDate = b.LastPracticeDate
+	}
+	if b.Longest > merged.Longest {
+		merged.Longest = b.Longest
+	}
+	merged.Freezes = a.Freezes + b.Freezes
+	if merged.Freezes > MaxFreezes {
+		merged.Freezes = MaxFreezes
+	}
+	if a.VacationFrom == "" || a.VacationTo == "" {
+		merged.VacationFrom = b.VacationFrom
+		merged.VacationTo = b.VacationTo
+	}
+	return merged
+}
@@ -0,0 +1,74 @@
+package streak_test
+
+import (
+	"testing"
+	"time"
+
+	"hpmaster/internal/fixtures"
+	"hpmaster/internal/streak"
+)
+
+type step struct {
+	Day           string       `json:"day"`
+	State         streak.State `json:"state"`
+	FreezesUsed   int          `json:"freezesUsed,omitempty"`
+	FreezesEarned int          `json:"freezesEarned,omitempty"`
+}
+
+// practice records practice on each of days (offsets from fixtures.Now) in
+// turn, starting from state.
+func practice(state streak.State, days ...int) []step {
+	var steps []step
+	for _, offset := range days {
+		now := fixtures.Now.AddDate(0, 0, offset)
+		update := streak.Practice(state, now)
+		state = update.State
+		steps = append(steps, step{
+			Day:           now.Format(streak.DateLayout),
+			State:         state,
+			FreezesUsed:   update.FreezesUsed,
+			FreezesEarned: update.FreezesEarned,
+		})
+	}
+	return steps
+}
+
+func TestPractice(t *testing.T) {
+	vacation := streak.State{
+		VacationFrom: fixtures.Now.AddDate(0, 0, 3).Format(streak.DateLayout),
+		VacationTo:   fixtures.Now.AddDate(0, 0, 6).Format(streak.DateLayout),
+	}
+	scenarios := map[string][]step{
+		"daily":            practice(streak.State{}, 0, 1, 2, 3, 4, 5, 6, 7, 8),
+		"same day":         practice(streak.State{}, 0, 0, 1, 1),
+		"missed day":       practice(streak.State{}, 0, 1, 3),
+		"freeze covers":    practice(streak.State{}, 0, 1, 2, 3, 4, 5, 6, 8, 9),
+		"freezes run out":  practice(streak.State{}, 0, 1, 2, 3, 4, 5, 6, 9),
+		"freezes capped":   practice(streak.State{Freezes: streak.MaxFreezes}, 0, 1, 2, 3, 4, 5, 6),
+		"vacation":         practice(vacation, 0, 1, 2, 7, 8),
+		"clock backwards":  practice(streak.State{}, 2, 1),
+		"longest survives": practice(streak.State{}, 0, 1, 2, 5, 6),
+	}
+	fixtures.Golden(t, "practice", scenarios)
+}
+
+func TestEffective(t *testing.T) {
+	state := streak.State{Current: 5, Longest: 5, Freezes: 1, LastPracticeDate: fixtures.Now.Format(streak.DateLayout)}
+	want := map[int]int{0: 5, 1: 5, 2: 5, 3: 0}
+	for offset, current := range want {
+		if got := streak.Effective(state, fixtures.Now.AddDate(0, 0, offset)); got != current {
+			t.Errorf("%d days later: got %d, want %d", offset, got, current)
+		}
+	}
+	if got := streak.Effective(streak.State{}, fixtures.Now); got != 0 {
+		t.Errorf("never practiced: got %d, want 0", got)
+	}
+}
+
+func TestDay(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+	late := time.Date(2024, 3, 15, 0, 30, 0, 0, cet)
+	if got := streak.Day(late).Format(streak.DateLayout); got != "2024-03-14" {
+		t.Errorf("got %s, want the UTC day 2024-03-14", got)
+	}
+}
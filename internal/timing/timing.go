@@ -0,0 +1,109 @@
+// Package timing measures how a request spends its latency budget.
+//
+// Every finished request emits its stage durations as CloudWatch metrics
+// (embedded metric format, written to the log). Requests slower than the
+// threshold additionally log the full breakdown to the slow-request log.
+package timing
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const metricsNamespace = "hpmaster"
+
+type Stage struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Budget collects the stage timings of one request. A nil *Budget is valid
+// and records nothing, so callers outside a request can pass nil.
+type Budget struct {
+	operation string
+	start     time.Time
+	stages    []Stage
+}
+
+func Start(operation string) *Budget {
+	return &Budget{operation: operation, start: time.Now()}
+}
+
+// Stage starts timing the named stage and returns the function that ends it.
+// Time spent in a stage that is entered several times adds up.
+func (b *Budget) Stage(name string) func() {
+	if b == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		for i := range b.stages {
+			if b.stages[i].Name == name {
+				b.stages[i].Duration += elapsed
+				return
+			}
+		}
+		b.stages = append(b.stages, Stage{Name: name, Duration: elapsed})
+	}
+}
+
+// Finish emits the metrics of the request and logs the breakdown if it took
+// longer than slowThreshold.
+func (b *Budget) Finish(slowThreshold time.Duration) {
+	if b == nil {
+		return
+	}
+	total := time.Since(b.start)
+	b.emitMetrics(total)
+	if total < slowThreshold {
+		return
+	}
+
+	var breakdown strings.Builder
+	accounted := time.Duration(0)
+	for _, stage := range b.stages {
+		fmt.Fprintf(&breakdown, " %s=%dms", stage.Name, stage.Duration.Milliseconds())
+		accounted += stage.Duration
+	}
+	log.Printf("Slow request %s: total=%dms%s other=%dms",
+		b.operation, total.Milliseconds(), breakdown.String(), (total - accounted).Milliseconds())
+}
+
+func (b *Budget) emitMetrics(total time.Duration) {
+	type metric struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit"`
+	}
+	metrics := []metric{{Name: "total", Unit: "Milliseconds"}}
+	record := map[string]interface{}{
+		"Operation": b.operation,
+		"total":     total.Milliseconds(),
+	}
+	for _, stage := range b.stages {
+		metrics = append(metrics, metric{Name: stage.Name, Unit: "Milliseconds"})
+		record[stage.Name] = stage.Duration.Milliseconds()
+	}
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixNano() / int64(time.Millisecond),
+		"CloudWatchMetrics": []interface{}{
+			map[string]interface{}{
+				"Namespace":  metricsNamespace,
+				"Dimensions": [][]string{{"Operation"}},
+				"Metrics":    metrics,
+			},
+		},
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Failed to marshal metrics: %v", err)
+		return
+	}
+	// The embedded metric format must be a log line of its own, without the
+	// log package's prefix
+	fmt.Println(string(data))
+}
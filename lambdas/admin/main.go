@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/cache"
+	"hpmaster/internal/identity"
+	"hpmaster/internal/store"
+)
+
+var (
+	userStore *store.Store
+	// userCache shares REDIS_ADDR with the words lambda, whose cached
+	// userIds must be dropped when users are merged.
+	userCache cache.Cache
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	userCache, err = cache.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create cache: %v", err)
+	}
+}
+
+type MergeRequest struct {
+	FromUserId string `json:"fromUserId"`
+	ToUserId   string `json:"toUserId"`
+}
+
+// UserView is what support sees of a user's account.
+type UserView struct {
+	User           store.User             `json:"user"`
+	WordStatistics []store.WordStatistics `json:"wordStatistics"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	admin, resp := authorizeAdmin(event)
+	if admin == nil {
+		return resp, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "POST /admin/users/merge":
+		return handleMergeUsers(admin, event)
+	case "GET /admin/users/{userId}/view":
+		return handleViewAsUser(admin, event)
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+// authorizeAdmin resolves the caller and returns it only if it has the admin
+// role. Otherwise the response to send back is returned instead.
+func authorizeAdmin(event events.APIGatewayProxyRequest) (*store.User, events.APIGatewayProxyResponse) {
+	userEmail, err := identity.ExtractAuthorizerEmail(event)
+	if err != nil {
+		return nil, events.APIGatewayProxyResponse{StatusCode: 401, Body: err.Error()}
+	}
+
+	user, err := userStore.FindUserByEmail(*userEmail)
+	if err != nil {
+		if err != store.ErrUserNotFound {
+			log.Printf("Error getting user: %v", err)
+		}
+		return nil, events.APIGatewayProxyResponse{StatusCode: 403, Body: "Forbidden"}
+	}
+	if !user.IsAdmin() {
+		return nil, events.APIGatewayProxyResponse{StatusCode: 403, Body: "Forbidden"}
+	}
+	return user, events.APIGatewayProxyResponse{}
+}
+
+func handleMergeUsers(admin *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req MergeRequest
+	err := json.Unmarshal([]byte(event.Body), &req)
+	if err != nil || req.FromUserId == "" || req.ToUserId == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+
+	fromUser, err := userStore.GetUser(req.FromUserId)
+	if err == store.ErrUserNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "User not found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	log.Printf("Admin %s merging user %s into %s", admin.Email, req.FromUserId, req.ToUserId)
+	result, err := userStore.MergeUsers(req.FromUserId, req.ToUserId)
+	if err != nil {
+		log.Printf("Error merging users: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to merge users"}, nil
+	}
+	if err := userCache.Delete(cache.UserIdKey(fromUser.Email)); err != nil {
+		log.Printf("Error dropping cached userId: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Users merged, but the cached userId is stale"}, nil
+	}
+
+	return api.JSON(result)
+}
+
+// handleViewAsUser shows support a user's profile and progress. It only
+// reads, and every access is written to the audit log before any data is
+// returned. The optional reason query parameter, e.g. a ticket number, is
+// logged with it.
+func handleViewAsUser(admin *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId := event.PathParameters["userId"]
+	if userId == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid userId"}, nil
+	}
+
+	user, err := userStore.GetUser(userId)
+	if err == store.ErrUserNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "User not found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	reason := event.QueryStringParameters["reason"]
+	if err := userStore.WriteAudit(userId, admin, store.AuditViewAsUser, reason, time.Now()); err != nil {
+		log.Printf("Error writing audit entry: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	log.Printf("Admin %s viewing user %s", admin.Email, userId)
+
+	stats, err := userStore.ListWordStatistics(userId)
+	if err != nil {
+		log.Printf("Error getting word statistics: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	return api.JSON(UserView{User: *user, WordStatistics: stats})
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -11,16 +12,33 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/google/uuid"
+
+	"hpmaster/internal/identity"
+	"hpmaster/internal/store"
 )
 
+// viewerCountryHeader is the caller's country as located by CloudFront, in
+// front of the edge-optimized API.
+const viewerCountryHeader = "CloudFront-Viewer-Country"
+
 var (
 	db             *dynamodb.DynamoDB
+	userStore      *store.Store
 	googleCertsURL = "https://www.googleapis.com/oauth2/v3/certs"
 	usersTableName = "Users"
 	region         = "eu-north-1"
 )
 
 func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	// Sign users up in the tables of the deployment's residency
+	if residency := userStore.Residency(); residency != "" {
+		region = store.Regions[residency]
+	}
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(region),
 	})
@@ -48,11 +66,25 @@ func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyR
 		} else {
 			return events.APIGatewayProxyResponse{StatusCode: 401, Body: "Unauthorized: Email not found"}, nil
 		}
+	} else if email, ok := identity.DevEmail(event); ok {
+		userEmail = email
+		name = email
 	} else {
 		return events.APIGatewayProxyResponse{StatusCode: 401, Body: "Unauthorized"}, nil
 	}
 
-	err := storeUserIfNotExists(userEmail, name)
+	residency, ok := signupResidency(event)
+	if !ok {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid residency"}, nil
+	}
+	err := storeUserIfNotExists(userEmail, name, residency)
+	if err == store.ErrUserDisabled {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "Account disabled"}, nil
+	}
+	if err == store.ErrWrongResidency {
+		// The app retries against the deployment of the user's residency
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Wrong data residency region"}, nil
+	}
 	if err != nil {
 		log.Printf("Error storing user: %v", err)
 		return events.APIGatewayProxyResponse{}, fmt.Errorf("could not store user in DB")
@@ -64,16 +96,41 @@ func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyR
 	}, nil
 }
 
-func storeUserIfNotExists(email string, name string) error {
+// signupResidency picks where a new user's data is kept. The app lets users
+// choose on signup and passes the choice in the residency query parameter.
+// Without a choice, the country CloudFront located the caller in decides. An
+// unknown residency is refused, false is returned.
+func signupResidency(event events.APIGatewayProxyRequest) (string, bool) {
+	if residency := event.QueryStringParameters["residency"]; residency != "" {
+		_, known := store.Regions[residency]
+		return residency, known
+	}
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, viewerCountryHeader) {
+			return store.ResidencyForCountry(value), true
+		}
+	}
+	return store.DefaultResidency, true
+}
+
+// storeUserIfNotExists signs the user up with the given residency, unless
+// this deployment doesn't keep data of that residency.
+func storeUserIfNotExists(email string, name string, residency string) error {
 	userId := uuid.New().String()
 
-	user, err := getUserByEmail(email)
-	if err != nil {
+	_, err := userStore.FindUserByEmail(email)
+	if err == nil {
+		return nil
+	}
+	if err == store.ErrUserDisabled || err == store.ErrWrongResidency {
+		return err
+	}
+	if err != store.ErrUserNotFound {
 		log.Printf("Error checking user existence: %v", err)
 		return err
 	}
-	if user != nil {
-		return nil
+	if err := userStore.CheckResidency(residency); err != nil {
+		return err
 	}
 
 	_, err = db.PutItem(&dynamodb.PutItemInput{
@@ -94,6 +151,9 @@ func storeUserIfNotExists(email string, name string) error {
 			"provider": {
 				S: aws.String("google"),
 			},
+			"residencyRegion": {
+				S: aws.String(residency),
+			},
 		},
 	})
 	if err != nil {
@@ -104,26 +164,6 @@ func storeUserIfNotExists(email string, name string) error {
 	return nil
 }
 
-func getUserByEmail(email string) (map[string]*dynamodb.AttributeValue, error) {
-	result, err := db.Query(&dynamodb.QueryInput{
-		TableName:              aws.String(usersTableName),
-		IndexName:              aws.String("email-userId-index"),
-		KeyConditionExpression: aws.String("email = :email"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":email": {
-				S: aws.String(email),
-			},
-		},
-	})
-	if err != nil {
-		return nil, err
-	}
-	if len(result.Items) == 0 {
-		return nil, nil
-	}
-	return result.Items[0], nil // Return the first item (if there are multiple)
-}
-
 func main() {
 	lambda.Start(HandleRequest)
 }
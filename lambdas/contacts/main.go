@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/notify"
+	"hpmaster/internal/store"
+)
+
+var (
+	userStore *store.Store
+	notifier  notify.Notifier
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	notifier, err = notify.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create notifier: %v", err)
+	}
+}
+
+type ContactRequest struct {
+	Email string `json:"email"`
+}
+
+type ContactStatus struct {
+	Status string `json:"status"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "GET /contacts":
+		return handleListContacts(user)
+	case "POST /contacts":
+		return handleRequestContact(user, event)
+	case "POST /contacts/{userId}/accept":
+		return handleAcceptContact(user, event.PathParameters["userId"])
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+// handleListContacts lists the caller's contacts and the requests waiting
+// for the caller. Outgoing requests are left out, they would tell which of
+// the requested emails are registered.
+func handleListContacts(user *store.User) (events.APIGatewayProxyResponse, error) {
+	contacts, err := userStore.ListContacts(user.UserId)
+	if err != nil {
+		log.Printf("Error listing contacts: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	visible := []store.Contact{}
+	for _, contact := range contacts {
+		if contact.Status != store.ContactOutgoing {
+			visible = append(visible, contact)
+		}
+	}
+	return api.JSON(visible)
+}
+
+// handleRequestContact asks the user with the given email to become a
+// contact. The response is the same whether or not the email is registered.
+func handleRequestContact(user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req ContactRequest
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil || req.Email == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+
+	recipient, err := userStore.FindUserByEmail(req.Email)
+	if err == store.ErrUserNotFound || err == store.ErrUserDisabled {
+		return api.JSON(ContactStatus{Status: "requested"})
+	}
+	if err != nil {
+		log.Printf("Error getting recipient: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if recipient.UserId == user.UserId {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Cannot add yourself as a contact"}, nil
+	}
+
+	if err := userStore.RequestContact(user, recipient, time.Now()); err != nil {
+		log.Printf("Error requesting contact: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	err = notifier.Notify(notify.Notification{
+		UserId:  recipient.UserId,
+		Type:    "contactRequest",
+		Message: user.Name + " wants to add you as a contact",
+	})
+	if err != nil {
+		// The request shows up in GET /contacts anyway
+		log.Printf("Error sending contact notification: %v", err)
+	}
+	return api.JSON(ContactStatus{Status: "requested"})
+}
+
+func handleAcceptContact(user *store.User, contactUserId string) (events.APIGatewayProxyResponse, error) {
+	err := userStore.AcceptContact(user.UserId, contactUserId, time.Now())
+	switch err {
+	case nil:
+		return api.JSON(ContactStatus{Status: store.ContactAccepted})
+	case store.ErrContactNotFound:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Contact request not found"}, nil
+	default:
+		log.Printf("Error accepting contact: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+var userStore *store.Store
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+}
+
+type RegisterDeviceRequest struct {
+	DeviceId   string `json:"deviceId"`
+	Platform   string `json:"platform"`
+	AppVersion string `json:"appVersion"`
+	PushToken  string `json:"pushToken"`
+}
+
+type DeviceSyncStatus struct {
+	DeviceId     string `json:"deviceId"`
+	Platform     string `json:"platform"`
+	AppVersion   string `json:"appVersion"`
+	LastSeenAt   string `json:"lastSeenAt"`
+	LastSyncedAt string `json:"lastSyncedAt,omitempty"`
+}
+
+type SyncStatusResponse struct {
+	ServerTime string             `json:"serverTime"`
+	Devices    []DeviceSyncStatus `json:"devices"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "POST /devices":
+		return handleRegisterDevice(user, event)
+	case "GET /sync/status":
+		return handleSyncStatus(user)
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+func handleRegisterDevice(user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req RegisterDeviceRequest
+	err := json.Unmarshal([]byte(event.Body), &req)
+	if err != nil || req.DeviceId == "" || req.Platform == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+
+	device, err := userStore.RegisterDevice(store.Device{
+		UserId:     user.UserId,
+		DeviceId:   req.DeviceId,
+		Platform:   req.Platform,
+		AppVersion: req.AppVersion,
+		PushToken:  req.PushToken,
+	})
+	if err != nil {
+		log.Printf("Error registering device: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to register device"}, nil
+	}
+
+	return api.JSON(toSyncStatus(*device))
+}
+
+func handleSyncStatus(user *store.User) (events.APIGatewayProxyResponse, error) {
+	devices, err := userStore.ListDevices(user.UserId)
+	if err != nil {
+		log.Printf("Error listing devices: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	response := SyncStatusResponse{
+		ServerTime: time.Now().Format(time.RFC3339),
+		Devices:    make([]DeviceSyncStatus, 0, len(devices)),
+	}
+	for _, device := range devices {
+		response.Devices = append(response.Devices, toSyncStatus(device))
+	}
+	return api.JSON(response)
+}
+
+func toSyncStatus(device store.Device) DeviceSyncStatus {
+	return DeviceSyncStatus{
+		DeviceId:     device.DeviceId,
+		Platform:     device.Platform,
+		AppVersion:   device.AppVersion,
+		LastSeenAt:   device.LastSeenAt,
+		LastSyncedAt: device.LastSyncedAt,
+	}
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+var userStore *store.Store
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+}
+
+// EventBanner is an event as shown in the app. Its eventId is passed as the
+// event parameter of GET /leaderboards to show the event's ranking.
+type EventBanner struct {
+	store.Event
+	Active bool `json:"active"`
+}
+
+// HandleRequest serves GET /events, the running and upcoming events ordered
+// by start time.
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if event.HTTPMethod != "GET" {
+		return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+	}
+	if user, failed := api.Caller(userStore, event); user == nil {
+		return failed, nil
+	}
+
+	all, err := userStore.ListEvents()
+	if err != nil {
+		log.Printf("Error listing events: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	now := time.Now()
+	banners := make([]EventBanner, 0, len(all))
+	for _, e := range all {
+		endsAt, err := time.Parse(time.RFC3339, e.EndsAt)
+		if err != nil || !now.Before(endsAt) {
+			continue
+		}
+		banners = append(banners, EventBanner{Event: e, Active: e.IsActive(now)})
+	}
+	sort.Slice(banners, func(i, j int) bool {
+		return store.CompareTimestamps(banners[i].StartsAt, banners[j].StartsAt) < 0
+	})
+
+	return api.JSON(banners)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
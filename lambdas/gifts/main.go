@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/notify"
+	"hpmaster/internal/store"
+)
+
+var (
+	userStore *store.Store
+	notifier  notify.Notifier
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	notifier, err = notify.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create notifier: %v", err)
+	}
+}
+
+type SendGiftRequest struct {
+	// ToEmail identifies the recipient, who must be an accepted contact.
+	ToEmail string `json:"toEmail"`
+	Kind    string `json:"kind"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "GET /gifts":
+		return handleListGifts(user)
+	case "POST /gifts":
+		return handleSendGift(user, event)
+	case "POST /gifts/{giftId}/claim":
+		return handleClaimGift(user, event.PathParameters["giftId"])
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+// handleListGifts lists the gifts waiting to be claimed by the caller.
+func handleListGifts(user *store.User) (events.APIGatewayProxyResponse, error) {
+	gifts, err := userStore.ListPendingGifts(user.UserId)
+	if err != nil {
+		log.Printf("Error listing gifts: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if gifts == nil {
+		gifts = []store.Gift{}
+	}
+	return api.JSON(gifts)
+}
+
+func handleSendGift(user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var req SendGiftRequest
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil || req.ToEmail == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+	if req.Kind != store.GiftStreakFreeze && req.Kind != store.GiftXPBoost {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid gift kind"}, nil
+	}
+
+	// Unknown emails and users who aren't contacts get the same response, so
+	// gifts can't be used to find out who is registered
+	notContact := events.APIGatewayProxyResponse{StatusCode: 403, Body: "Gifts can only be sent to contacts"}
+	recipient, err := userStore.FindUserByEmail(req.ToEmail)
+	if err == store.ErrUserNotFound || err == store.ErrUserDisabled {
+		return notContact, nil
+	}
+	if err != nil {
+		log.Printf("Error getting recipient: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if recipient.UserId == user.UserId {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Cannot send a gift to yourself"}, nil
+	}
+	isContact, err := userStore.IsContact(user.UserId, recipient.UserId)
+	if err != nil {
+		log.Printf("Error getting contact: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if !isContact {
+		return notContact, nil
+	}
+
+	gift, err := userStore.SendGift(user, recipient.UserId, req.Kind, time.Now())
+	if err == store.ErrGiftAlreadySent {
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Gift already sent today"}, nil
+	}
+	if err != nil {
+		log.Printf("Error sending gift: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to send gift"}, nil
+	}
+
+	err = notifier.Notify(notify.Notification{
+		UserId:  recipient.UserId,
+		Type:    "gift",
+		Message: user.Name + " sent you a gift",
+		Data:    gift,
+	})
+	if err != nil {
+		// The gift is stored and shows up in GET /gifts anyway
+		log.Printf("Error sending gift notification: %v", err)
+	}
+	return api.JSON(gift)
+}
+
+func handleClaimGift(user *store.User, giftId string) (events.APIGatewayProxyResponse, error) {
+	gift, err := userStore.ClaimGift(user.UserId, giftId)
+	switch err {
+	case nil:
+		return api.JSON(gift)
+	case store.ErrGiftNotFound:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Gift not found"}, nil
+	case store.ErrGiftClaimed:
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Gift already claimed"}, nil
+	case store.ErrInventoryFull:
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Inventory is full"}, nil
+	default:
+		log.Printf("Error claiming gift: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to claim gift"}, nil
+	}
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
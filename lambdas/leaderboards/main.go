@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/cache"
+	"hpmaster/internal/store"
+)
+
+const (
+	maxLeaderboardLimit = 100
+	// Top lists are read far more often than they change noticeably
+	topListTTL = 30 * time.Second
+)
+
+var (
+	userStore *store.Store
+	topLists  cache.Cache
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	topLists, err = cache.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create cache: %v", err)
+	}
+}
+
+// RankedEntry is a user's place on a board. Other users are only shown by
+// the display name they chose, never by userId.
+type RankedEntry struct {
+	Rank  int    `json:"rank"`
+	Name  string `json:"name"`
+	Score int    `json:"score"`
+	// IsMe marks the caller's entry
+	IsMe bool `json:"isMe,omitempty"`
+}
+
+type LeaderboardResponse struct {
+	Board   string        `json:"board"`
+	Entries []RankedEntry `json:"entries"`
+	// Me is the caller's entry on the board, if any
+	Me *RankedEntry `json:"me,omitempty"`
+}
+
+// HandleRequest serves GET /leaderboards. The board is chosen with at most
+// one of the event, deck and category query parameters, e.g. ?deck=<deckId>
+// for the ranking on a deck. Without any of them the global board is
+// returned. ?group=<groupId>&deck=<deckId> is the class ranking on a deck
+// assigned to a group, open to its teacher and members.
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if event.HTTPMethod != "GET" {
+		return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+	}
+
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	limit := 10
+	if limitStr := event.QueryStringParameters["limit"]; limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxLeaderboardLimit {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid limit parameter"}, nil
+		}
+	}
+
+	board, ok := boardFromQuery(event.QueryStringParameters)
+	if !ok {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Only one of event, deck and category can be set"}, nil
+	}
+	if groupId := event.QueryStringParameters[store.ScopeGroup]; groupId != "" {
+		failed, ok := checkGroupBoard(user, groupId, event.QueryStringParameters[store.ScopeDeck])
+		if !ok {
+			return failed, nil
+		}
+		board = store.BoardKey(store.ScopeGroup, groupId, store.ScopeDeck, event.QueryStringParameters[store.ScopeDeck])
+	}
+	entries, err := topLeaderboard(board, limit)
+	if err != nil {
+		log.Printf("Error reading leaderboard %s: %v", board, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	response := LeaderboardResponse{Board: board, Entries: make([]RankedEntry, 0, len(entries))}
+	for i, entry := range entries {
+		ranked := RankedEntry{Rank: i + 1, Name: entry.Name, Score: entry.Score, IsMe: entry.UserId == user.UserId}
+		response.Entries = append(response.Entries, ranked)
+		if ranked.IsMe {
+			response.Me = &ranked
+		}
+	}
+	if response.Me == nil {
+		response.Me = myEntry(board, user)
+	}
+
+	return api.JSON(response)
+}
+
+// checkGroupBoard reports whether the user may see the group's board of the
+// deck: the deck must be assigned to the group, and the user must teach the
+// group or be a member of it.
+func checkGroupBoard(user *store.User, groupId string, deckId string) (events.APIGatewayProxyResponse, bool) {
+	if deckId == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "A group board needs a deck"}, false
+	}
+	group, err := userStore.GetGroup(groupId)
+	if err == store.ErrGroupNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, false
+	}
+	if err != nil {
+		log.Printf("Error reading group %s: %v", groupId, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, false
+	}
+	if group.TeacherId != user.UserId && !group.HasMember(user.UserId) {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "Forbidden"}, false
+	}
+	for _, assigned := range group.Decks {
+		if assigned == deckId {
+			return events.APIGatewayProxyResponse{}, true
+		}
+	}
+	return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, false
+}
+
+// myEntry returns the user's entry on the board when it is outside the top
+// list, nil if the user has no score there.
+func myEntry(board string, user *store.User) *RankedEntry {
+	mine, err := userStore.GetLeaderboardEntry(board, user.UserId)
+	if err != nil {
+		log.Printf("Error reading leaderboard entry: %v", err)
+		return nil
+	}
+	if mine == nil {
+		return nil
+	}
+	rank, err := userStore.LeaderboardRank(board, mine.Score)
+	if err != nil {
+		// The entry is still worth showing without its rank
+		log.Printf("Error ranking leaderboard entry: %v", err)
+	}
+	return &RankedEntry{Rank: rank, Name: mine.Name, Score: mine.Score, IsMe: true}
+}
+
+// topLeaderboard is store.TopLeaderboard behind the top list cache.
+func topLeaderboard(board string, limit int) ([]store.LeaderboardEntry, error) {
+	key := cache.LeaderboardPrefix + board + ":" + strconv.Itoa(limit)
+	var entries []store.LeaderboardEntry
+	if cached, exists, err := topLists.Get(key); err != nil {
+		log.Printf("Error reading leaderboard cache: %v", err)
+	} else if exists && json.Unmarshal([]byte(cached), &entries) == nil {
+		return entries, nil
+	}
+
+	entries, err := userStore.TopLeaderboard(board, limit)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(entries); err == nil {
+		if err := topLists.Set(key, string(data), topListTTL); err != nil {
+			log.Printf("Error writing leaderboard cache: %v", err)
+		}
+	}
+	return entries, nil
+}
+
+// boardFromQuery returns the board chosen by the query. It reports false if
+// several scopes are set, the words lambda only writes single scope boards
+// apart from the group boards, which the group parameter picks.
+func boardFromQuery(query map[string]string) (string, bool) {
+	var parts []string
+	for _, scope := range []string{store.ScopeEvent, store.ScopeDeck, store.ScopeCategory} {
+		if id := query[scope]; id != "" {
+			parts = append(parts, scope, id)
+		}
+	}
+	if query[store.ScopeGroup] != "" {
+		// The deck is the group's, the other scopes don't combine with it
+		return "", len(parts) == 0 || (len(parts) == 2 && query[store.ScopeDeck] != "")
+	}
+	if len(parts) > 2 {
+		return "", false
+	}
+	return store.BoardKey(parts...), true
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
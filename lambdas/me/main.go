@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/ratelimit"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+var (
+	userStore *store.Store
+	// limiter reads the usage counted by the words lambda. It must share
+	// its USAGE_LIMITS.
+	limiter *ratelimit.Limiter
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	limits, err := ratelimit.LoadLimits(os.Getenv("USAGE_LIMITS"))
+	if err != nil {
+		log.Fatalf("Failed to load usage limits: %v", err)
+	}
+	limiter = ratelimit.New(userStore, limits)
+}
+
+type Profile struct {
+	UserId string        `json:"userId"`
+	Email  string        `json:"email"`
+	Name   string        `json:"name"`
+	XP     int           `json:"xp"`
+	Streak StreakSummary `json:"streak"`
+	// XPBoosts are claimed gifts not used yet
+	XPBoosts int `json:"xpBoosts"`
+	// MasteredCategories are the categories the user certified in, see
+	// GET /certifications for the certificates
+	MasteredCategories []string `json:"masteredCategories"`
+}
+
+type StreakSummary struct {
+	Current      int    `json:"current"`
+	Longest      int    `json:"longest"`
+	Freezes      int    `json:"freezes"`
+	VacationFrom string `json:"vacationFrom,omitempty"`
+	VacationTo   string `json:"vacationTo,omitempty"`
+}
+
+type Preferences struct {
+	// VacationFrom and VacationTo are inclusive YYYY-MM-DD dates. Leave
+	// both empty to end the vacation.
+	VacationFrom string `json:"vacationFrom"`
+	VacationTo   string `json:"vacationTo"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "GET /me":
+		return handleGetProfile(user)
+	case "PUT /me/preferences":
+		return handleSetPreferences(user, event)
+	case "GET /me/usage":
+		return handleGetUsage(user)
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+func handleGetProfile(user *store.User) (events.APIGatewayProxyResponse, error) {
+	mastered := append([]string{}, user.MasteredCategories...)
+	sort.Strings(mastered)
+	return api.JSON(Profile{
+		UserId: user.UserId,
+		Email:  user.Email,
+		Name:   user.Name,
+		XP:     user.XP,
+		Streak: StreakSummary{
+			Current:      streak.Effective(user.State, time.Now()),
+			Longest:      user.Longest,
+			Freezes:      user.Freezes,
+			VacationFrom: user.VacationFrom,
+			VacationTo:   user.VacationTo,
+		},
+		XPBoosts:           user.XPBoosts,
+		MasteredCategories: mastered,
+	})
+}
+
+func handleSetPreferences(user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	var prefs Preferences
+	if err := json.Unmarshal([]byte(event.Body), &prefs); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+	if msg := validateVacation(prefs, time.Now()); msg != "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: msg}, nil
+	}
+
+	err := userStore.SetVacation(user.UserId, prefs.VacationFrom, prefs.VacationTo)
+	if errors.Is(err, store.ErrVacationInPast) {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "vacationFrom is before the last practice"}, nil
+	}
+	if err != nil {
+		log.Printf("Error updating preferences: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to update preferences"}, nil
+	}
+	return api.JSON(prefs)
+}
+
+// validateVacation checks the requested range. Vacations can't start before
+// today (UTC), otherwise days already missed could be covered after the fact.
+func validateVacation(prefs Preferences, now time.Time) string {
+	if prefs.VacationFrom == "" && prefs.VacationTo == "" {
+		return ""
+	}
+	from, err := time.Parse(streak.DateLayout, prefs.VacationFrom)
+	if err != nil {
+		return "Invalid vacationFrom"
+	}
+	to, err := time.Parse(streak.DateLayout, prefs.VacationTo)
+	if err != nil {
+		return "Invalid vacationTo"
+	}
+	if from.Before(streak.Day(now)) {
+		return "vacationFrom is in the past"
+	}
+	if to.Before(from) {
+		return "vacationTo is before vacationFrom"
+	}
+	if to.Sub(from) >= streak.MaxVacationDays*24*time.Hour {
+		return "Vacation is too long"
+	}
+	return ""
+}
+
+// handleGetUsage returns the user's usage in the current period next to the
+// limits, so the app can warn before requests are refused with 429.
+func handleGetUsage(user *store.User) (events.APIGatewayProxyResponse, error) {
+	usage, err := limiter.Usage(user.UserId, time.Now())
+	if err != nil {
+		log.Printf("Error reading usage: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(usage)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
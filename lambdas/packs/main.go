@@ -0,0 +1,117 @@
+package main
+
+import (
+	"log"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+var userStore *store.Store
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+}
+
+// PackListing is a pack as shown in the catalog, without its word list.
+type PackListing struct {
+	PackId      string `json:"packId"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Difficulty  string `json:"difficulty"`
+	Language    string `json:"language"`
+	Size        int    `json:"size"`
+	Installed   bool   `json:"installed"`
+}
+
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+
+	route := event.HTTPMethod + " " + event.Resource
+	switch route {
+	case "GET /packs":
+		return handleListPacks(user, event)
+	case "POST /packs/{packId}/install":
+		return handleInstallPack(user, event.PathParameters["packId"], true)
+	case "DELETE /packs/{packId}/install":
+		return handleInstallPack(user, event.PathParameters["packId"], false)
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+// handleListPacks lists the catalog, optionally filtered by the language and
+// difficulty query parameters.
+func handleListPacks(user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	packs, err := userStore.ListPacks()
+	if err != nil {
+		log.Printf("Error listing packs: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	installed := make(map[string]bool, len(user.InstalledPacks))
+	for _, packId := range user.InstalledPacks {
+		installed[packId] = true
+	}
+
+	language := event.QueryStringParameters["language"]
+	difficulty := event.QueryStringParameters["difficulty"]
+	listings := make([]PackListing, 0, len(packs))
+	for _, pack := range packs {
+		if (language != "" && pack.Language != language) || (difficulty != "" && pack.Difficulty != difficulty) {
+			continue
+		}
+		listings = append(listings, PackListing{
+			PackId:      pack.PackId,
+			Name:        pack.Name,
+			Description: pack.Description,
+			Difficulty:  pack.Difficulty,
+			Language:    pack.Language,
+			Size:        pack.Size,
+			Installed:   installed[pack.PackId],
+		})
+	}
+	sort.Slice(listings, func(i, j int) bool { return listings[i].Name < listings[j].Name })
+
+	return api.JSON(listings)
+}
+
+func handleInstallPack(user *store.User, packId string, install bool) (events.APIGatewayProxyResponse, error) {
+	pack, err := userStore.GetPack(packId)
+	if err == store.ErrPackNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Pack not found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error getting pack: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	if err := userStore.SetPackInstalled(user.UserId, pack.PackId, install); err != nil {
+		log.Printf("Error updating installed packs: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to update installed packs"}, nil
+	}
+	return api.JSON(PackListing{
+		PackId:      pack.PackId,
+		Name:        pack.Name,
+		Description: pack.Description,
+		Difficulty:  pack.Difficulty,
+		Language:    pack.Language,
+		Size:        pack.Size,
+		Installed:   install,
+	})
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
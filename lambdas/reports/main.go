@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/report"
+	"hpmaster/internal/store"
+)
+
+// reportLinkTTL is how long a shared report link stays valid, long enough
+// for a teacher to review the month. Links are served by GET /reports/{token}
+// rather than presigned, so they can be revoked any time before.
+const reportLinkTTL = 30 * 24 * time.Hour
+
+// Report formats
+const (
+	formatHTML = "html"
+	formatPDF  = "pdf"
+)
+
+var (
+	userStore *store.Store
+	archive   *report.Archive
+	// reportLinkURL is the REPORT_LINK_URL links are made of: the public URL
+	// of GET /reports/{token} up to the token, e.g.
+	// https://api.example.com/reports/
+	reportLinkURL string
+	initErr       error
+)
+
+func init() {
+	var err error
+	userStore, err = store.NewFromSession()
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+	// Reports hold user data, so they stay in the region of the residency
+	region := store.Region
+	if residency := userStore.Residency(); residency != "" {
+		region = store.Regions[residency]
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(region),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create AWS session: %v", err)
+	}
+
+	reportsBucket := os.Getenv("REPORTS_BUCKET")
+	reportLinkURL = os.Getenv("REPORT_LINK_URL")
+	switch {
+	case reportsBucket == "":
+		initErr = errors.New("REPORTS_BUCKET is not set")
+	case reportLinkURL == "":
+		initErr = errors.New("REPORT_LINK_URL is not set")
+	}
+	archive = report.NewArchive(s3.New(sess), reportsBucket)
+}
+
+type ReportLink struct {
+	Month string `json:"month"`
+	// Token identifies the link for DELETE /me/reports/{token}
+	Token     string `json:"token"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type RevokedReports struct {
+	Revoked int `json:"revoked"`
+}
+
+// HandleRequest serves the progress reports:
+//
+//   - POST /me/reports renders the caller's report of the month given by the
+//     month query parameter (YYYY-MM, default the current month) in the
+//     format query parameter (html, the default, or pdf) and returns a link
+//     to it.
+//   - POST /groups/{groupId}/members/{userId}/reports does the same for a
+//     member of a group, if the caller teaches the group.
+//   - GET /reports/{token} serves a report to anyone holding a link, e.g.
+//     the user's teacher. The route has no authorizer.
+//   - DELETE /me/reports/{token} revokes one link of the caller, DELETE
+//     /me/reports all of them, and deletes the reports.
+func HandleRequest(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if initErr != nil {
+		log.Fatalf("Initialization failed: %v", initErr)
+	}
+	route := event.HTTPMethod + " " + event.Resource
+	if route == "GET /reports/{token}" {
+		return handleGetReport(event.PathParameters["token"])
+	}
+
+	user, failed := api.Caller(userStore, event)
+	if user == nil {
+		return failed, nil
+	}
+	switch route {
+	case "POST /me/reports":
+		return handleCreateReport(user, user, event)
+	case "POST /groups/{groupId}/members/{userId}/reports":
+		return handleCreateMemberReport(user, event)
+	case "DELETE /me/reports":
+		return handleRevokeAll(user)
+	case "DELETE /me/reports/{token}":
+		return handleRevoke(user, event.PathParameters["token"])
+	default:
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+}
+
+// handleCreateMemberReport lets a teacher generate the report of a member of
+// the group. The access is recorded in the member's audit log.
+func handleCreateMemberReport(teacher *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	groupId, memberId := event.PathParameters["groupId"], event.PathParameters["userId"]
+	group, err := userStore.GetGroup(groupId)
+	if err == store.ErrGroupNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error reading group %s: %v", groupId, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if group.TeacherId != teacher.UserId || !group.HasMember(memberId) {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "Forbidden"}, nil
+	}
+
+	member, err := userStore.GetUser(memberId)
+	if err == store.ErrUserNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error reading user %s: %v", memberId, err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if err := userStore.WriteAudit(memberId, teacher, store.AuditTeacherReport, "group "+groupId, time.Now()); err != nil {
+		log.Printf("Error writing audit entry: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return handleCreateReport(teacher, member, event)
+}
+
+// handleCreateReport renders the report of user, on behalf of creator.
+func handleCreateReport(creator *store.User, user *store.User, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	now := time.Now()
+	month := now
+	if monthStr := event.QueryStringParameters["month"]; monthStr != "" {
+		var err error
+		month, err = report.ParseMonth(monthStr)
+		if err != nil || month.After(now) {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid month parameter"}, nil
+		}
+	}
+	format := event.QueryStringParameters["format"]
+	if format == "" {
+		format = formatHTML
+	}
+	if format != formatHTML && format != formatPDF {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid format parameter"}, nil
+	}
+
+	attempts, err := userStore.ListAttempts(user.UserId)
+	if err != nil {
+		log.Printf("Error listing attempts: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	progress := report.Build(*user, month, attempts, now)
+
+	link, err := storeReport(creator.UserId, user.UserId, progress, format, now)
+	if err != nil {
+		log.Printf("Error storing report: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(link)
+}
+
+// storeReport uploads the rendered report and creates a link to it. Every
+// report gets a key of its own, so earlier links keep showing what was
+// shared.
+func storeReport(creatorId string, userId string, progress report.Report, format string, now time.Time) (*ReportLink, error) {
+	var page bytes.Buffer
+	contentType := "text/html; charset=utf-8"
+	render := progress.HTML
+	if format == formatPDF {
+		contentType = "application/pdf"
+		render = progress.PDF
+	}
+	if err := render(&page); err != nil {
+		return nil, err
+	}
+
+	name := progress.Month + "-" + uuid.New().String() + "." + format
+	key, err := archive.Put(userId, name, contentType, page.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(reportLinkTTL)
+	link, err := userStore.PutReportLink(store.ReportLink{
+		UserId:      userId,
+		Key:         key,
+		ContentType: contentType,
+		Month:       progress.Month,
+		CreatedBy:   creatorId,
+		CreatedAt:   now.Format(time.RFC3339),
+		ExpiresAt:   expiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ReportLink{
+		Month:     progress.Month,
+		Token:     link.Token,
+		URL:       reportLinkURL + link.Token,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// handleGetReport serves the report a link points to. Revoked and expired
+// links get 404, and nothing is cached so revoking takes effect at once.
+func handleGetReport(token string) (events.APIGatewayProxyResponse, error) {
+	link, err := userStore.FindReportLink(token, time.Now())
+	if err == store.ErrReportLinkNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error finding report link: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	body, contentType, err := archive.Get(link.Key)
+	if err != nil {
+		log.Printf("Error reading report: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type":  contentType,
+			"Cache-Control": "no-store",
+		},
+		Body: string(body),
+	}
+	if contentType == "application/pdf" {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+	return response, nil
+}
+
+func handleRevoke(user *store.User, token string) (events.APIGatewayProxyResponse, error) {
+	link, err := userStore.GetReportLink(user.UserId, token)
+	if err == store.ErrReportLinkNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+	if err == nil {
+		err = userStore.DeleteReportLink(user.UserId, token)
+	}
+	if err == nil {
+		err = archive.Delete(link.Key)
+	}
+	if err != nil {
+		log.Printf("Error revoking report link: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(RevokedReports{Revoked: 1})
+}
+
+// handleRevokeAll deletes every link and report of the user. The links go
+// first, so a failure half way never leaves a link working.
+func handleRevokeAll(user *store.User) (events.APIGatewayProxyResponse, error) {
+	links, err := userStore.ListReportLinks(user.UserId)
+	if err != nil {
+		log.Printf("Error listing report links: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	for _, link := range links {
+		if err := userStore.DeleteReportLink(user.UserId, link.Token); err != nil {
+			log.Printf("Error revoking report link: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+		}
+	}
+	if _, err := archive.DeleteAll(user.UserId); err != nil {
+		log.Printf("Error deleting reports: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(RevokedReports{Revoked: len(links)})
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
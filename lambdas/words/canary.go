@@ -0,0 +1,88 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const canaryWords = 5
+
+type CanaryStep struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"durationMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+type CanaryReport struct {
+	OK         bool         `json:"ok"`
+	DurationMs int64        `json:"durationMs"`
+	Steps      []CanaryStep `json:"steps"`
+}
+
+// handleCanary serves the self-test used by synthetic monitoring. The route
+// bypasses the authorizer, so it is guarded by the X-Canary-Secret header and
+// only acts on the sandbox user CANARY_USER_ID; a development identity is
+// never accepted for it. It fetches words and posts results for them like
+// the app does and answers 500 if any step failed.
+func handleCanary(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if canarySecret == "" || canaryUserId == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Not Found"}, nil
+	}
+	secret := ""
+	for name, value := range event.Headers {
+		if strings.EqualFold(name, "X-Canary-Secret") {
+			secret = value
+		}
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(canarySecret)) != 1 {
+		return events.APIGatewayProxyResponse{StatusCode: 403, Body: "Forbidden"}, nil
+	}
+
+	start := time.Now()
+	report := CanaryReport{OK: true}
+	step := func(name string, run func() error) {
+		stepStart := time.Now()
+		err := run()
+		result := CanaryStep{Name: name, DurationMs: time.Since(stepStart).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+		}
+		report.Steps = append(report.Steps, result)
+	}
+
+	var words []Word
+	step("getWords", func() error {
+		var err error
+		words, err = getWords(canaryUserId, canaryWords, nil)
+		return err
+	})
+	if report.OK {
+		step("postResults", func() error {
+			results := make([]WordResults, 0, len(words))
+			for i, word := range words {
+				results = append(results, WordResults{Word: word.Word, IsCorrect: i%2 == 0})
+			}
+			_, err := recordResults(canaryUserId, results, "", nil)
+			return err
+		})
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	responseBody, err := json.Marshal(report)
+	if err != nil {
+		log.Printf("Error marshalling response: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	statusCode := 200
+	if !report.OK {
+		log.Printf("Canary failed: %s", responseBody)
+		statusCode = 500
+	}
+	return events.APIGatewayProxyResponse{StatusCode: statusCode, Body: string(responseBody)}, nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+const (
+	// certificationMinCategorySize is the fewest words a category needs to
+	// be certified in; a sample of a smaller one says little.
+	certificationMinCategorySize = 30
+	// The sample is certificationSampleRatio of the category, but at least
+	// certificationMinSample and at most certificationMaxSample words.
+	certificationSampleRatio = 0.2
+	certificationMinSample   = 20
+	certificationMaxSample   = 50
+	certificationPassRatio   = 0.9
+	// certificationTimePerWord is the time allowed per sampled word
+	certificationTimePerWord = 30 * time.Second
+	// Answers arriving slightly late are still accepted to allow for latency
+	certificationGracePeriod = 30 * time.Second
+	// certificationCooldown is how long a user waits before attempting a
+	// category again
+	certificationCooldown = 24 * time.Hour
+)
+
+type StartCertificationRequest struct {
+	Category string `json:"category"`
+}
+
+// CertificationQuestion deliberately doesn't say which option is correct,
+// certifications are graded by the server.
+type CertificationQuestion struct {
+	Word    string   `json:"word"`
+	Options []string `json:"options"`
+}
+
+type CertificationSession struct {
+	CertificationId string                  `json:"certificationId"`
+	Category        string                  `json:"category"`
+	ExpiresAt       string                  `json:"expiresAt"`
+	PassRatio       float64                 `json:"passRatio"`
+	Questions       []CertificationQuestion `json:"questions"`
+}
+
+type CertificationAnswer struct {
+	Word   string `json:"word"`
+	Answer string `json:"answer"`
+}
+
+// Certificate is a passed certification. The sampled words are left out.
+type Certificate struct {
+	CertificationId string  `json:"certificationId"`
+	Category        string  `json:"category"`
+	Score           float64 `json:"score"`
+	CompletedAt     string  `json:"completedAt"`
+}
+
+type CertificationResult struct {
+	CertificationId string  `json:"certificationId"`
+	Category        string  `json:"category"`
+	Status          string  `json:"status"`
+	Score           float64 `json:"score"`
+	Correct         int     `json:"correct"`
+	Total           int     `json:"total"`
+	InTime          bool    `json:"inTime"`
+}
+
+// handleStartCertification picks a sample of the category's words and starts
+// the clock on a certification session.
+func handleStartCertification(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	var req StartCertificationRequest
+	if err := json.Unmarshal([]byte(event.Body), &req); err != nil || req.Category == "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+
+	user, err := userStore.GetUser(*userId)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if user.HasMastered(req.Category) {
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Category already mastered"}, nil
+	}
+
+	words := categoryWords(req.Category)
+	if len(words) == 0 {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Unknown category"}, nil
+	}
+	if len(words) < certificationMinCategorySize {
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Category too small to certify"}, nil
+	}
+	sample := sampleWords(words, certificationSampleSize(len(words)))
+
+	now := time.Now()
+	cert := store.Certification{
+		UserId:          *userId,
+		CertificationId: uuid.New().String(),
+		Category:        req.Category,
+		CreatedAt:       now.Format(time.RFC3339),
+		ExpiresAt:       now.Add(time.Duration(len(sample)) * certificationTimePerWord).Format(time.RFC3339),
+		Status:          store.CertificationPending,
+	}
+	session := CertificationSession{
+		CertificationId: cert.CertificationId,
+		Category:        cert.Category,
+		ExpiresAt:       cert.ExpiresAt,
+		PassRatio:       certificationPassRatio,
+	}
+	for _, word := range sample {
+		cert.Words = append(cert.Words, word.Word)
+		options := append([]string{word.Correct}, word.Incorrect...)
+		rand.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+		session.Questions = append(session.Questions, CertificationQuestion{Word: word.Word, Options: options})
+	}
+
+	err = userStore.CreateCertification(cert, certificationCooldown, now)
+	if err == store.ErrCertificationCooldown {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 429,
+			Headers:    map[string]string{"Retry-After": strconv.Itoa(int(certificationCooldown.Seconds()))},
+			Body:       "Category attempted too recently",
+		}, nil
+	}
+	if err != nil {
+		log.Printf("Error creating certification: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(session)
+}
+
+// handleListCertificates returns the certificates of the categories the user
+// mastered.
+func handleListCertificates(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	certs, err := userStore.ListCertificates(*userId)
+	if err != nil {
+		log.Printf("Error listing certificates: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return store.CompareTimestamps(certs[i].CompletedAt, certs[j].CompletedAt) < 0
+	})
+	certificates := make([]Certificate, 0, len(certs))
+	for _, cert := range certs {
+		certificates = append(certificates, Certificate{
+			CertificationId: cert.CertificationId,
+			Category:        cert.Category,
+			Score:           cert.Score,
+			CompletedAt:     cert.CompletedAt,
+		})
+	}
+	return api.JSON(certificates)
+}
+
+// handleCertificationAnswers grades a certification session. It can only be
+// submitted once.
+func handleCertificationAnswers(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	var answers []CertificationAnswer
+	if err := json.Unmarshal([]byte(event.Body), &answers); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+
+	cert, err := userStore.GetCertification(*userId, event.PathParameters["certificationId"])
+	if err == store.ErrCertificationNotFound {
+		return events.APIGatewayProxyResponse{StatusCode: 404, Body: "Certification not found"}, nil
+	}
+	if err != nil {
+		log.Printf("Error getting certification: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	if cert.Status != store.CertificationPending {
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Certification already completed"}, nil
+	}
+
+	result := gradeCertification(cert, answers, time.Now())
+	err = userStore.CompleteCertification(cert, result.Status == store.CertificationPassed, result.Score)
+	if err == store.ErrCertificationCompleted {
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: "Certification already completed"}, nil
+	}
+	if err != nil {
+		log.Printf("Error completing certification: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(result)
+}
+
+func gradeCertification(cert *store.Certification, answers []CertificationAnswer, now time.Time) CertificationResult {
+	given := make(map[string]string, len(answers))
+	for _, answer := range answers {
+		given[answer.Word] = answer.Answer
+	}
+
+	result := CertificationResult{
+		CertificationId: cert.CertificationId,
+		Category:        cert.Category,
+		Status:          store.CertificationFailed,
+		Total:           len(cert.Words),
+	}
+	for _, word := range cert.Words {
+		if answer, ok := given[word]; ok && gradeAnswer(word, answer) {
+			result.Correct++
+		}
+	}
+	if result.Total > 0 {
+		result.Score = float64(result.Correct) / float64(result.Total)
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, cert.ExpiresAt)
+	result.InTime = err == nil && now.Before(expiresAt.Add(certificationGracePeriod))
+	if result.InTime && result.Score >= certificationPassRatio {
+		result.Status = store.CertificationPassed
+	}
+	return result
+}
+
+// categoryWords returns the words of the category.
+func categoryWords(category string) []Word {
+	var words []Word
+	for _, word := range cachedWords {
+		if word.Category == category {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// certificationSampleSize is how many of a category's words a certification
+// samples, in proportion to the category's size.
+func certificationSampleSize(categorySize int) int {
+	size := int(math.Ceil(float64(categorySize) * certificationSampleRatio))
+	if size < certificationMinSample {
+		size = certificationMinSample
+	}
+	if size > certificationMaxSample {
+		size = certificationMaxSample
+	}
+	return size
+}
+
+// sampleWords returns up to size random words of words, which it shuffles.
+func sampleWords(words []Word, size int) []Word {
+	rand.Shuffle(len(words), func(i, j int) { words[i], words[j] = words[j], words[i] })
+	if len(words) > size {
+		words = words[:size]
+	}
+	return words
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"hpmaster/internal/store"
+)
+
+// activeEvents returns the cached events running at now.
+func activeEvents(now time.Time) []store.Event {
+	var active []store.Event
+	for _, event := range cachedEvents {
+		if event.IsActive(now) {
+			active = append(active, event)
+		}
+	}
+	return active
+}
+
+// eventMultiplier is the highest XP bonus of the active events the word
+// takes part in, or 0 if there is none.
+func eventMultiplier(word Word, now time.Time) float64 {
+	multiplier := 0.0
+	for _, event := range activeEvents(now) {
+		if event.Includes(word.Category) && event.XPMultiplier > multiplier {
+			multiplier = event.XPMultiplier
+		}
+	}
+	return multiplier
+}
+
+// pickRandomWords returns up to limit random words among those include
+// accepts. While an event with a special category runs, half of them are
+// taken from the event's categories if possible.
+func pickRandomWords(limit int, include func(Word) bool, now time.Time) []Word {
+	categories := make(map[string]bool)
+	for _, event := range activeEvents(now) {
+		if event.Category != "" {
+			categories[event.Category] = true
+		}
+	}
+	if len(categories) == 0 {
+		return getRandomWords(limit, include)
+	}
+
+	words := getRandomWords((limit+1)/2, func(word Word) bool {
+		return categories[word.Category] && include(word)
+	})
+	picked := make(map[string]bool, len(words))
+	for _, word := range words {
+		picked[word.Word] = true
+	}
+	return append(words, getRandomWords(limit-len(words), func(word Word) bool {
+		return !picked[word.Word] && include(word)
+	})...)
+}
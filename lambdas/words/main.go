@@ -3,94 +3,296 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"os"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/cache"
+	"hpmaster/internal/grading"
+	"hpmaster/internal/identity"
+	"hpmaster/internal/projection"
+	"hpmaster/internal/question"
+	"hpmaster/internal/ratelimit"
+	"hpmaster/internal/scoring"
+	"hpmaster/internal/selection"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+	"hpmaster/internal/timing"
 )
 
+// xpBoostMultiplier applies to the XP of a results upload that spends one of
+// the user's XP boosts, see store.GiftXPBoost.
+const xpBoostMultiplier = 2
+
+// slowRequestThreshold is the latency above which the stage breakdown of a
+// request is logged. Overridden by SLOW_REQUEST_MS.
+var slowRequestThreshold = time.Second
+
+// userCacheTTL bounds how long a user keeps resolving from the cache if the
+// entry isn't deleted when the user is disabled or merged, see
+// cache.UserIdKey.
+const userCacheTTL = time.Hour
+
 var (
-	db                 *dynamodb.DynamoDB
-	wordsTableName     = "Words"
-	usersTableName     = "Users"
-	wordStatsTableName = "WordStatistics"
-	region             = "eu-north-1"
-
-	userCache      map[string]string // In-memory cache for users (email->userId)
-	userCacheMutex sync.Mutex        // Mutex to protect userCache
-	cachedWords    map[string]Word
-	once           sync.Once
-	initErr        error
+	userStore *store.Store
+
+	userCache         cache.Cache // email -> userId
+	cachedWords       map[string]Word
+	cachedPacks       map[string]store.Pack
+	cachedEvents      []store.Event
+	cacheEpoch        string // cache.EpochKey when the content was loaded
+	newWordsPerDay    = 20   // Overridden by NEW_WORDS_PER_DAY
+	scoringTable      scoring.Table
+	answerRules       grading.Rules
+	limiter           *ratelimit.Limiter
+	statementSigner   *question.Signer  // From QUESTION_SECRET, true/false questions are disabled without it
+	selectionStrategy = defaultStrategy // Overridden by SELECTION_STRATEGY
+	canarySecret      string            // CANARY_SECRET, the canary is disabled without it
+	canaryUserId      string            // CANARY_USER_ID, the canary's sandbox user
+	once              sync.Once
+	initErr           error
 )
 
 func init() {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
+	var err error
+	userStore, err = store.NewFromSession()
 	if err != nil {
 		log.Fatalf("Failed to create AWS session: %v", err)
 	}
-	db = dynamodb.New(sess)
 
-	userCache = make(map[string]string)
-	cachedWords = make(map[string]Word)
+	userCache, err = cache.FromEnv()
+	if err != nil {
+		log.Fatalf("Failed to create cache: %v", err)
+	}
+
+	scoringTable, err = scoring.LoadTable(os.Getenv("SCORING_TABLE"))
+	if err != nil {
+		initErr = err
+		return
+	}
+	if slowMs := os.Getenv("SLOW_REQUEST_MS"); slowMs != "" {
+		ms, err := strconv.Atoi(slowMs)
+		if err != nil {
+			initErr = fmt.Errorf("invalid SLOW_REQUEST_MS: %w", err)
+			return
+		}
+		slowRequestThreshold = time.Duration(ms) * time.Millisecond
+	}
+
+	if strategy := os.Getenv("SELECTION_STRATEGY"); strategy != "" {
+		if _, exists := selection.Get(strategy); !exists && strategy != defaultStrategy {
+			initErr = fmt.Errorf("unknown SELECTION_STRATEGY %q, known are %v", strategy, selection.Names())
+			return
+		}
+		selectionStrategy = strategy
+	}
+
+	if secret := os.Getenv("QUESTION_SECRET"); secret != "" {
+		statementSigner = question.NewSigner(secret, statementTokenTTL)
+	}
+	canarySecret = os.Getenv("CANARY_SECRET")
+	canaryUserId = os.Getenv("CANARY_USER_ID")
 
-	words, err := fetchWordsFromDynamoDB()
+	answerRules, err = grading.LoadRules(os.Getenv("ANSWER_RULES"))
 	if err != nil {
-		initErr = fmt.Errorf("Initialization error", err)
+		initErr = err
 		return
 	}
+	limits, err := ratelimit.LoadLimits(os.Getenv("USAGE_LIMITS"))
+	if err != nil {
+		initErr = err
+		return
+	}
+	limiter = ratelimit.New(userStore, limits)
+	if perDay := os.Getenv("NEW_WORDS_PER_DAY"); perDay != "" {
+		newWordsPerDay, err = strconv.Atoi(perDay)
+		if err != nil {
+			initErr = fmt.Errorf("invalid NEW_WORDS_PER_DAY: %w", err)
+			return
+		}
+	}
+
+	// Read the epoch first, an invalidation during the load then triggers
+	// another one
+	cacheEpoch, _, err = userCache.Get(cache.EpochKey)
+	if err != nil {
+		log.Printf("Error reading cache epoch: %v", err)
+	}
+	initErr = loadContent()
+}
+
+// loadContent loads the words, packs and events into memory.
+func loadContent() error {
+	words, err := userStore.ListWords()
+	if err != nil {
+		return fmt.Errorf("Initialization error: %w", err)
+	}
 	if len(words) == 0 {
-		initErr = fmt.Errorf("Failed to initialize the cache, no words available")
+		return fmt.Errorf("Failed to initialize the cache, no words available")
 	}
+	loaded := make(map[string]Word, len(words))
 	for _, word := range words {
-		cachedWords[word.Word] = word
+		loaded[word.Word] = word
+	}
+	cachedWords = loaded
+
+	// Without packs every user simply practices from all words
+	cachedPacks = make(map[string]store.Pack)
+	packs, err := userStore.ListPacks()
+	if err != nil {
+		log.Printf("Failed to load packs: %v", err)
+	}
+	for _, pack := range packs {
+		cachedPacks[pack.PackId] = pack
 	}
 
+	// Without events nothing is boosted
+	cachedEvents, err = userStore.ListEvents()
+	if err != nil {
+		log.Printf("Failed to load events: %v", err)
+	}
+	return nil
+}
+
+// refreshContent reloads the content if the caches were invalidated since it
+// was loaded, see cache.EpochKey. The epoch is read through the local tier,
+// so Redis is asked at most once per local TTL.
+func refreshContent() {
+	epoch, _, err := userCache.Get(cache.EpochKey)
+	if err != nil {
+		log.Printf("Error reading cache epoch: %v", err)
+		return
+	}
+	if epoch == cacheEpoch {
+		return
+	}
+	if err := loadContent(); err != nil {
+		// Keep serving what was loaded before
+		log.Printf("Error reloading content: %v", err)
+		return
+	}
+	cacheEpoch = epoch
 }
 
 type User struct {
 	UserId    string `json:"userId"`
-	Email     string `json:"email`
+	Email     string `json:"email"`
 	CreatedAt string `json:"createdAt"`
 	Name      string `json:"name"`
 	Provider  string `json:"provider"`
 }
 
-type Word struct {
-	Word      string   `json:"word"`
-	Correct   string   `json:"correct"`
-	Incorrect []string `json:"incorrect"`
-}
+type Word = store.Word
 
 type WordResults struct {
 	Word      string `json:"word"`
 	IsCorrect bool   `json:"isCorrect"`
+	// Answer is the typed answer in spelling mode, or "true" or "false" for
+	// the Statement of a true/false question, sent back with its Token. If
+	// it is set the server grades it and IsCorrect is ignored.
+	Answer         string `json:"answer,omitempty"`
+	Statement      string `json:"statement,omitempty"`
+	Token          string `json:"token,omitempty"`
+	ResponseTimeMs int    `json:"responseTimeMs,omitempty"`
+	HintUsed       bool   `json:"hintUsed,omitempty"`
+	Retries        int    `json:"retries,omitempty"`
+	// AttemptId identifies the answer on the client, e.g. a UUID, so a
+	// retried upload isn't logged twice. AnsweredAt (RFC3339) is when it was
+	// answered, for results synced later; it defaults to the upload time.
+	// An AttemptId requires AnsweredAt: the logged attemptId is built from
+	// both, so it has to be the same on every try.
+	AttemptId  string `json:"attemptId,omitempty"`
+	AnsweredAt string `json:"answeredAt,omitempty"`
 }
 
-type WordStatistics struct {
-	UserId       string  `json:"userId"`
-	Word         string  `json:"word"`
-	Attempts     int     `json:"attempts"`
-	Success      int     `json:"success"`
-	SuccessRatio float32 `json:"successRatio"`
+// Uploaded results are capped to these, anything beyond earns nothing more
+// and only skews the statistics.
+const (
+	maxRetries        = 10
+	maxResponseTimeMs = 10 * 60 * 1000
+	// maxAnswerAge is how late answers given offline may be uploaded
+	maxAnswerAge = 7 * 24 * time.Hour
+	// answerClockSkew is how far ahead of ours the client's clock may be
+	answerClockSkew = 5 * time.Minute
+)
+
+// maxResultsPerUpload bounds an upload so recording it, which takes a few
+// DynamoDB calls per result, fits in the lambda timeout. Clients syncing a
+// longer offline session upload it in parts.
+const maxResultsPerUpload = 100
+
+type ResultsResponse struct {
+	XPAwarded int            `json:"xpAwarded"`
+	TotalXP   int            `json:"totalXp"`
+	Streak    *streak.Update `json:"streak,omitempty"`
+	// XPBoosted is set if a gifted XP boost multiplied XPAwarded
+	XPBoosted bool `json:"xpBoosted,omitempty"`
+	// Duplicates counts results whose attemptId was logged by an earlier
+	// upload. They are graded but not scored again.
+	Duplicates int `json:"duplicates,omitempty"`
+	// Graded holds the server's verdict on every result sent with an answer
+	Graded []GradedResult `json:"graded,omitempty"`
 }
 
+type GradedResult struct {
+	Word      string `json:"word"`
+	Answer    string `json:"answer"`
+	Statement string `json:"statement,omitempty"`
+	IsCorrect bool   `json:"isCorrect"`
+}
+
+type WordStatistics = store.WordStatistics
+
 func HandleRequest(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	if initErr != nil {
 		log.Fatalf("Initialization failed: %v", initErr)
 	}
+	refreshContent()
 	method := event.RequestContext.HTTPMethod
+	switch event.Resource {
+	case "/canary":
+		if method != "GET" {
+			return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+		}
+		return handleCanary(event)
+	case "/reviews":
+		if method != "GET" {
+			return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+		}
+		return handleGetReviews(event)
+	case "/me/word-stats":
+		if method != "GET" {
+			return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+		}
+		return handleGetWordStats(event)
+	case "/certifications":
+		switch method {
+		case "GET":
+			return handleListCertificates(event)
+		case "POST":
+			return handleStartCertification(event)
+		}
+		return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+	case "/certifications/{certificationId}/answers":
+		if method != "POST" {
+			return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+		}
+		return handleCertificationAnswers(event)
+	case "/suggestions":
+		if method != "POST" {
+			return events.APIGatewayProxyResponse{StatusCode: 405, Body: "Method Not Allowed"}, nil
+		}
+		return handleSuggestWord(event)
+	}
+
 	switch method {
 	case "GET":
 		return handleGetWords(event)
@@ -101,34 +303,7 @@ func HandleRequest(ctx context.Context, event events.APIGatewayProxyRequest) (ev
 	}
 }
 
-// Shall only be called from init(). Not protected by mutex
-func fetchWordsFromDynamoDB() ([]Word, error) {
-
-	// Query your Words table here
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(wordsTableName),
-	}
-
-	result, err := db.Scan(input)
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan table: %w", err)
-	}
-
-	var words []Word
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &words)
-	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal words: %w", err)
-	}
-
-	return words, nil
-}
-
 func handleGetWords(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	userEmail, err := extractEmail(event)
-	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 401, Body: fmt.Sprint("%v", err)}, nil
-	}
-
 	numWordsStr := event.QueryStringParameters["numWords"]
 	if numWordsStr == "" {
 		numWordsStr = "10"
@@ -138,75 +313,137 @@ func handleGetWords(event events.APIGatewayProxyRequest) (events.APIGatewayProxy
 	if err != nil || numWords <= 0 {
 		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid numWords parameter"}, nil
 	}
+	opts, msg := questionOptions(event.QueryStringParameters)
+	if msg != "" {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: msg}, nil
+	}
 
-	userId, err := getUserIdByEmail(*userEmail)
-	if err != nil || userId == nil {
-		if err != nil {
-			log.Printf("Error getting user id: %v", err)
-		}
-		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "User not found"}, nil
+	budget := timing.Start("getWords")
+	defer budget.Finish(slowRequestThreshold)
+
+	stop := budget.Stage("userLookup")
+	userId, errResponse := authenticate(event)
+	stop()
+	if userId == nil {
+		return errResponse, nil
+	}
+	if limited, ok := countUsage(*userId, ratelimit.Sessions, 1); !ok {
+		return limited, nil
 	}
 
-	words, err := getWords(*userId, numWords)
+	var words []Word
+	strategy := event.QueryStringParameters["strategy"]
+	if strategy == "" {
+		strategy = selectionStrategy
+	}
+	if strategy == defaultStrategy {
+		words, err = getWords(*userId, numWords, budget)
+	} else {
+		selector, exists := selection.Get(strategy)
+		if !exists {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid strategy parameter"}, nil
+		}
+		words, err = selectWords(*userId, numWords, selector, event.QueryStringParameters, budget)
+	}
 	if err != nil {
 		log.Printf("Error retrieving words: %v", err)
 		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
 	}
 
-	responseBody, err := json.Marshal(words)
-	if err != nil {
-		log.Printf("Error marshalling response: %v", err)
-		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	// Clients that don't ask for a question shape get the stored words
+	var response interface{} = words
+	if opts != nil {
+		opts.UserId = *userId
+		response, err = question.Build(words, *opts)
+		if err != nil {
+			log.Printf("Error building questions: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+		}
 	}
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Body:       string(responseBody),
-	}, nil
+	stop = budget.Stage("marshal")
+	defer stop()
+	return api.JSON(response)
 }
 
 func getPoorPerformanceWords(userID string, limit int) ([]Word, error) {
-	// Query for poor performance words (userId = :userId) from WordStatistics table
-	performanceInput := &dynamodb.QueryInput{
-		TableName:              aws.String(wordStatsTableName),
-		IndexName:              aws.String("userId-successRatio-index"), // GSI on userId and successRatio
-		KeyConditionExpression: aws.String("userId = :userId"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":userId": {S: aws.String(userID)},
-		},
-		ScanIndexForward: aws.Bool(true),             // Sort in descending order (poorest first)
-		Limit:            aws.Int64(int64(limit / 2)), // Limit to half of the requested limit for poor performance words
-	}
-
-	performanceResult, err := db.Query(performanceInput)
+	// Half of the requested words are the poorest performing ones
+	stats, err := userStore.WeakestWordStatistics(userID, limit/2)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query performance: %w", err)
+		return nil, err
 	}
 
-	// Extract words from the result
 	var poorPerformanceWords []string
-	for _, item := range performanceResult.Items {
-		var wp WordStatistics
-		err = dynamodbattribute.UnmarshalMap(item, &wp)
+	for _, stat := range stats {
+		poorPerformanceWords = append(poorPerformanceWords, stat.Word)
+	}
+	return hydrateWords(poorPerformanceWords)
+}
+
+// hydrateWords resolves word names to complete words, keeping their order.
+// Words missing from the cache, e.g. added after the container started, are
+// fetched from the Words table and cached. Words that no longer exist are
+// left out.
+func hydrateWords(names []string) ([]Word, error) {
+	var misses []string
+	for _, name := range names {
+		if _, exists := cachedWords[name]; !exists {
+			misses = append(misses, name)
+		}
+	}
+	if len(misses) > 0 {
+		fetched, err := userStore.GetWords(misses)
 		if err != nil {
-			return nil, fmt.Errorf("failed to unmarshal poor performance word: %w", err)
+			return nil, err
+		}
+		// Requests are handled one at a time per container, so nothing
+		// reads the cache concurrently
+		for _, word := range fetched {
+			cachedWords[word.Word] = word
 		}
-		poorPerformanceWords = append(poorPerformanceWords, wp.Word)
 	}
 
-	// Fetch the complete Word objects from the Words table
-	var allPoorPerformanceWords []Word
-	for _, word := range poorPerformanceWords {
-		if completeWord, exists := cachedWords[word]; exists {
-			allPoorPerformanceWords = append(allPoorPerformanceWords, completeWord)
+	words := make([]Word, 0, len(names))
+	for _, name := range names {
+		if word, exists := cachedWords[name]; exists {
+			words = append(words, word)
 		}
 	}
+	return words, nil
+}
+
+// practiceFilter reports which words the user practices: those of the packs
+// the user installed, or all words if none are, except those of categories
+// the user mastered.
+func practiceFilter(userID string) (func(Word) bool, error) {
+	user, err := userStore.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
 
-	return allPoorPerformanceWords, nil
+	var allowed map[string]bool
+	for _, packId := range user.InstalledPacks {
+		pack, exists := cachedPacks[packId]
+		if !exists {
+			continue
+		}
+		if allowed == nil {
+			allowed = make(map[string]bool)
+		}
+		for _, word := range pack.Words {
+			allowed[word] = true
+		}
+	}
+	return func(word Word) bool {
+		if word.Category != "" && user.HasMastered(word.Category) {
+			return false
+		}
+		return allowed == nil || allowed[word.Word]
+	}, nil
 }
 
-// Fetch random words
-func getRandomWords(limit int) []Word {
+// Fetch random words among those include accepts
+func getRandomWords(limit int, include func(Word) bool) []Word {
 
 	var randomWords []Word
 
@@ -216,6 +453,9 @@ func getRandomWords(limit int) []Word {
 	// Initialize the reservoir to hold the first 'limit' words
 	i := 0
 	for _, word := range cachedWords {
+		if !include(word) {
+			continue
+		}
 		if i < limit {
 			// Fill the reservoir with the first 'limit' words
 			randomWords = append(randomWords, word)
@@ -232,9 +472,18 @@ func getRandomWords(limit int) []Word {
 	return randomWords
 }
 
-func getWords(userID string, limit int) ([]Word, error) {
+func getWords(userID string, limit int, budget *timing.Budget) ([]Word, error) {
+	stop := budget.Stage("packFilter")
+	include, err := practiceFilter(userID)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+
 	// Step 1: Fetch Poor Performance Words (with word details)
+	stop = budget.Stage("statsQuery")
 	poorPerformanceWords, err := getPoorPerformanceWords(userID, limit)
+	stop()
 	if err != nil {
 		return nil, err
 	}
@@ -245,7 +494,7 @@ func getWords(userID string, limit int) ([]Word, error) {
 
 	// Add poor performance words first
 	for _, word := range poorPerformanceWords {
-		if _, exists := seenWords[word.Word]; !exists {
+		if _, exists := seenWords[word.Word]; !exists && include(word) {
 			allWords = append(allWords, word)
 			seenWords[word.Word] = true
 		}
@@ -253,10 +502,41 @@ func getWords(userID string, limit int) ([]Word, error) {
 
 	// Step 3: If we don't have enough words, fetch random words
 	if len(allWords) < limit {
-		randomWords := getRandomWords(limit - len(allWords))
+		stop = budget.Stage("statsQuery")
+		stats, err := userStore.ListWordStatistics(userID)
+		stop()
+		if err != nil {
+			return nil, err
+		}
+		defer budget.Stage("selection")()
+		practiced := make(map[string]bool, len(stats))
+		for _, stat := range stats {
+			practiced[stat.Word] = true
+		}
+		isCandidate := func(word Word) bool {
+			return !seenWords[word.Word] && include(word)
+		}
 
-		for _, word := range randomWords {
-			if _, exists := seenWords[word.Word]; !exists {
+		// Introduce at most the remaining daily allowance of never practiced
+		// words and use practiced ones for the rest
+		newBudget := remainingNewWords(stats, time.Now())
+		dropped := 0
+		for _, word := range pickRandomWords(limit-len(allWords), isCandidate, time.Now()) {
+			if !practiced[word.Word] {
+				if newBudget <= 0 {
+					dropped++
+					continue
+				}
+				newBudget--
+			}
+			allWords = append(allWords, word)
+			seenWords[word.Word] = true
+		}
+		if dropped > 0 {
+			reviewWords := getRandomWords(dropped, func(word Word) bool {
+				return practiced[word.Word] && isCandidate(word)
+			})
+			for _, word := range reviewWords {
 				allWords = append(allWords, word)
 				seenWords[word.Word] = true
 			}
@@ -271,161 +551,377 @@ func getWords(userID string, limit int) ([]Word, error) {
 	return allWords, nil
 }
 
+// remainingNewWords is how many never practiced words may still be
+// introduced to the user today.
+func remainingNewWords(stats []WordStatistics, now time.Time) int {
+	today := streak.Day(now).Format(time.RFC3339)
+	remaining := newWordsPerDay
+	for _, stat := range stats {
+		if store.CompareTimestamps(stat.FirstSeenAt, today) >= 0 {
+			remaining--
+		}
+	}
+	return remaining
+}
+
 func handleResults(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
-	userEmail, err := extractEmail(event)
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	var wordResults []WordResults
+	err := json.Unmarshal([]byte(event.Body), &wordResults)
 	if err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: 401, Body: fmt.Sprint("%v", err)}, nil
+		log.Printf("Invalid request body: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+	if len(wordResults) > maxResultsPerUpload {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 400,
+			Body:       fmt.Sprintf("Too many results, at most %d per upload", maxResultsPerUpload),
+		}, nil
+	}
+	now := time.Now()
+	for i, result := range wordResults {
+		if result.Retries < 0 || result.ResponseTimeMs < 0 {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+		}
+		if result.AttemptId != "" && !store.IsValidClientAttemptId(result.AttemptId) {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid attemptId"}, nil
+		}
+		if result.AttemptId != "" && result.AnsweredAt == "" {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "attemptId requires answeredAt"}, nil
+		}
+		if _, ok := answeredAt(result, now); !ok {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid answeredAt"}, nil
+		}
+		// Only statements the server served are graded
+		if result.Statement != "" && (statementSigner == nil ||
+			!statementSigner.Verify(result.Token, *userId, result.Word, result.Statement, now)) {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid statement token"}, nil
+		}
+		if result.Retries > maxRetries {
+			wordResults[i].Retries = maxRetries
+		}
+		if result.ResponseTimeMs > maxResponseTimeMs {
+			wordResults[i].ResponseTimeMs = maxResponseTimeMs
+		}
+	}
+	if limited, ok := countUsage(*userId, ratelimit.Words, int64(len(wordResults))); !ok {
+		return limited, nil
 	}
 
-	userId, err := getUserIdByEmail(*userEmail)
-	if err != nil || userId == nil {
-		if err != nil {
-			log.Printf("Error getting user id: %v", err)
+	budget := timing.Start("postResults")
+	defer budget.Finish(slowRequestThreshold)
+	response, err := recordResults(*userId, wordResults, identity.DeviceId(event), budget)
+	if err != nil {
+		log.Printf("Error recording results: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to update statistics"}, nil
+	}
+
+	return api.JSON(response)
+}
+
+// recordResults grades, logs and scores uploaded results and updates the
+// statistics, XP, streak and leaderboards of the user.
+func recordResults(userId string, wordResults []WordResults, deviceId string, budget *timing.Budget) (*ResultsResponse, error) {
+	var response ResultsResponse
+	for i, result := range wordResults {
+		if result.Answer == "" {
+			continue
+		}
+		if result.Statement != "" {
+			wordResults[i].IsCorrect = gradeStatement(result.Word, result.Statement, result.Answer)
+		} else {
+			wordResults[i].IsCorrect = gradeAnswer(result.Word, result.Answer)
 		}
-		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "User not found"}, nil
+		response.Graded = append(response.Graded, GradedResult{
+			Word:      result.Word,
+			Answer:    result.Answer,
+			Statement: result.Statement,
+			IsCorrect: wordResults[i].IsCorrect,
+		})
 	}
 
-	var wordResults []WordResults
-	err = json.Unmarshal([]byte(event.Body), &wordResults)
+	now := time.Now()
+	user, err := userStore.GetUser(userId)
 	if err != nil {
-		log.Printf("Invalid request body: %v", err)
-		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	groups, err := userGroups(user)
+	if err != nil {
+		return nil, err
 	}
 
-	// Process and update each word result
+	// The attempts are logged together with everything projected from them,
+	// see package projection
+	attempts := make([]store.Attempt, 0, len(wordResults))
+	attemptIds := make([]string, 0, len(wordResults))
 	for _, result := range wordResults {
-		err := updateWordStatistics(*userId, result)
+		at, _ := answeredAt(result, now)
+		attemptId := store.NewAttemptId(at)
+		if result.AttemptId != "" {
+			attemptId = store.ClientAttemptId(at, result.AttemptId)
+		}
+		attempts = append(attempts, store.Attempt{
+			UserId:         userId,
+			AttemptId:      attemptId,
+			Word:           result.Word,
+			IsCorrect:      result.IsCorrect,
+			Answer:         result.Answer,
+			Statement:      result.Statement,
+			ResponseTimeMs: result.ResponseTimeMs,
+			HintUsed:       result.HintUsed,
+			Retries:        result.Retries,
+			XP:             scoreResult(result, now),
+			Boards:         leaderboards(user, groups, result.Word, now),
+			DeviceId:       deviceId,
+			AnsweredAt:     at.UTC().Format(time.RFC3339Nano),
+		})
+		attemptIds = append(attemptIds, attemptId)
+	}
+
+	stop := budget.Stage("recordAttempts")
+	logged, err := userStore.LoggedAttemptIds(userId, attemptIds)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	// A boost is only spent on an upload that scores anything new. A
+	// concurrent retry of the same upload may still record the attempts
+	// first, the boost is lost then.
+	scoresNew := false
+	for _, attempt := range attempts {
+		scoresNew = scoresNew || (!logged[attempt.AttemptId] && attempt.XP > 0)
+	}
+	if scoresNew {
+		response.XPBoosted, err = userStore.UseXPBoost(userId)
 		if err != nil {
-			log.Printf("Error updating word statistics: %v", err)
-			return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Failed to update statistics"}, nil
+			stop()
+			return nil, fmt.Errorf("failed to use xp boost: %w", err)
+		}
+		if response.XPBoosted {
+			for i := range attempts {
+				attempts[i].XP *= xpBoostMultiplier
+			}
 		}
 	}
+	recorded, err := userStore.RecordAttempts(user, attempts, projection.Apply)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	response.Duplicates = len(attempts) - len(recorded)
+	response.XPAwarded = projection.XP(recorded)
 
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Body:       "Word resultss successfully uploaded",
-	}, nil
-}
-
-func extractEmail(event events.APIGatewayProxyRequest) (*string, error) {
-	var userEmail string
-	authorizer := event.RequestContext.Authorizer
+	stop = budget.Stage("userUpdate")
+	defer stop()
+	user, err = userStore.GetUser(userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	response.TotalXP = user.XP
+	if len(attempts) > 0 {
+		// Duplicates count too: the streak is only updated after the
+		// attempts are recorded, so a retry repairs it if that failed before
+		response.Streak, err = recordPractice(user, attempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update streak: %w", err)
+		}
+	}
 
-	if email, ok := authorizer["email"].(string); ok {
-		userEmail = email
-	} else if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
-		// Handle custom claims (if your Authorizer outputs claims in Payload V2.0)
-		if emailClaim, exists := claims["email"].(string); exists {
-			userEmail = emailClaim
-		} else {
-			return nil, errors.New("Unauthorized: Email not found")
+	if deviceId != "" {
+		if err := userStore.MarkDeviceSynced(userId, deviceId, now); err != nil {
+			// The results are stored, the device just shows an older sync time
+			log.Printf("Error updating device sync status: %v", err)
 		}
-	} else {
-		return nil, errors.New("Unauthorized")
 	}
-	return &userEmail, nil
+	return &response, nil
+}
+
+// authenticate resolves the userId of the caller. If that fails the response
+// to send back is returned instead.
+func authenticate(event events.APIGatewayProxyRequest) (*string, events.APIGatewayProxyResponse) {
+	userEmail, err := identity.ExtractEmail(event)
+	if err != nil {
+		return nil, events.APIGatewayProxyResponse{StatusCode: 401, Body: err.Error()}
+	}
 
+	userId, err := getUserIdByEmail(*userEmail)
+	if err != nil {
+		return nil, api.UserError(err)
+	}
+	if limited, ok := countUsage(*userId, ratelimit.Requests, 1); !ok {
+		return nil, limited
+	}
+	return userId, events.APIGatewayProxyResponse{}
 }
 
 func getUserIdByEmail(email string) (*string, error) {
-	if userId, exists := userCache[email]; exists {
-		return &userId, nil // Return cached user
-	}
-
-	userCacheMutex.Lock()
-	defer userCacheMutex.Unlock()
-	// Check again after acquiring the lock (double-check locking)
-	// It's possible another goroutine already fetched the user in the meantime.
-	if userId, exists := userCache[email]; exists {
-		return &userId, nil // Return cached user (after the lock)
-	}
-
-	result, err := db.Query(&dynamodb.QueryInput{
-		TableName:              aws.String(usersTableName),
-		IndexName:              aws.String("email-userId-index"),
-		KeyConditionExpression: aws.String("email = :email"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":email": {
-				S: aws.String(email),
-			},
-		},
-	})
+	key := cache.UserIdKey(email)
+	userId, exists, err := userCache.Get(key)
+	if err != nil {
+		// The cache is an optimization, fall back to DynamoDB
+		log.Printf("Error reading user cache: %v", err)
+	}
+	if exists {
+		// The mapping is cached, whether the user may still use it is not
+		err := userStore.CheckActive(userId)
+		if err == nil || err == store.ErrUserDisabled {
+			return &userId, err
+		}
+		if err != store.ErrUserNotFound {
+			return nil, err
+		}
+		// Merged since it was cached, look up the surviving user
+		if err := userCache.Delete(key); err != nil {
+			log.Printf("Error deleting user cache: %v", err)
+		}
+	}
+
+	user, err := userStore.FindUserByEmail(email)
 	if err != nil {
 		return nil, err
 	}
-	if len(result.Items) == 0 {
-		return nil, errors.New("No user found")
+	if err := userCache.Set(key, user.UserId, userCacheTTL); err != nil {
+		log.Printf("Error writing user cache: %v", err)
 	}
-	userId := result.Items[0]["userId"].S
-	userCache[email] = *userId
-	return userId, nil
+	return &user.UserId, nil
 }
 
-func updateWordStatistics(userId string, result WordResults) error {
-	// Define the primary key (userId and word)
-	key := map[string]*dynamodb.AttributeValue{
-		"userId": {S: aws.String(userId)},
-		"word":   {S: aws.String(result.Word)},
+// recordPractice records the days of the attempts in the user's streak.
+func recordPractice(user *store.User, attempts []store.Attempt) (*streak.Update, error) {
+	update, err := projection.Practice(user.State, attempts)
+	if err != nil {
+		return nil, err
+	}
+	if update.State == user.State {
+		return &update, nil
+	}
+	err = userStore.SaveStreak(user.UserId, user.State, update.State)
+	if err == store.ErrStreakConflict {
+		// A concurrent upload already recorded practice
+		return nil, nil
 	}
-
-	var wordStats WordStatistics
-
-	resultItem, err := db.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String(wordStatsTableName),
-		Key:       key,
-	})
 	if err != nil {
-		log.Printf("Error getting WordStatistics: %v", err)
-		return err
+		return nil, err
 	}
+	return &update, nil
+}
 
-	if resultItem.Item != nil {
-		err = dynamodbattribute.UnmarshalMap(resultItem.Item, &wordStats)
+// userGroups returns the groups the user is a member of. Groups deleted since
+// are skipped.
+func userGroups(user *store.User) ([]store.Group, error) {
+	groups := make([]store.Group, 0, len(user.Groups))
+	for _, groupId := range user.Groups {
+		group, err := userStore.GetGroup(groupId)
+		if err == store.ErrGroupNotFound {
+			continue
+		}
 		if err != nil {
-			log.Printf("Error unmarshalling result: %v", err)
-			return err
+			return nil, fmt.Errorf("failed to get group %s: %w", groupId, err)
 		}
-	} else {
-		wordStats = WordStatistics{
-			Word:         result.Word,
-			UserId:       userId,
-			Attempts:     0,
-			Success:      0,
-			SuccessRatio: 0,
-		}
-	}
-	wordStats.Attempts++
-	if result.IsCorrect {
-		wordStats.Success++
-	}
-	wordStats.SuccessRatio = float32(wordStats.Success) / float32(wordStats.Attempts)
-
-	// Build the update expression
-	updateExpression := "SET attempts = :attempts, " +
-		"success = :success, " +
-		"successRatio = :successRatio"
-
-	// Define the expression attribute values
-	expressionValues := map[string]*dynamodb.AttributeValue{
-		":attempts":     {N: aws.String(fmt.Sprintf("%d", wordStats.Attempts))},
-		":success":      {N: aws.String(fmt.Sprintf("%d", wordStats.Success))},
-		":successRatio": {N: aws.String(fmt.Sprintf("%f", wordStats.SuccessRatio))},
-	}
-
-	// Perform the update
-	_, err = db.UpdateItem(&dynamodb.UpdateItemInput{
-		TableName:                 aws.String(wordStatsTableName),
-		Key:                       key,
-		UpdateExpression:          aws.String(updateExpression),
-		ExpressionAttributeValues: expressionValues,
-		ReturnValues:              aws.String("UPDATED_NEW"),
-	})
+		groups = append(groups, *group)
+	}
+	return groups, nil
+}
+
+// leaderboards returns the boards an answer to word scores on: the global
+// board, the board of the word's category, the deck boards of installed packs
+// holding it, the boards of the user's groups on assigned decks holding it and
+// the boards of active events. The canary's sandbox user stays off the
+// leaderboards.
+func leaderboards(user *store.User, groups []store.Group, word string, now time.Time) []string {
+	if user.UserId == canaryUserId {
+		return nil
+	}
+	boards := []string{store.BoardKey()}
+	category := cachedWords[word].Category
+	if category != "" {
+		boards = append(boards, store.BoardKey(store.ScopeCategory, category))
+	}
+	for _, packId := range user.InstalledPacks {
+		if packContains(packId, word) {
+			boards = append(boards, store.BoardKey(store.ScopeDeck, packId))
+		}
+	}
+	for _, group := range groups {
+		for _, deckId := range group.Decks {
+			if packContains(deckId, word) {
+				boards = append(boards, store.BoardKey(store.ScopeGroup, group.GroupId, store.ScopeDeck, deckId))
+			}
+		}
+	}
+	for _, event := range activeEvents(now) {
+		if event.Includes(category) {
+			boards = append(boards, store.BoardKey(store.ScopeEvent, event.EventId))
+		}
+	}
+	return boards
+}
+
+func packContains(packId string, word string) bool {
+	for _, packWord := range cachedPacks[packId].Words {
+		if packWord == word {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeAnswer checks a typed answer against the word's correct answer and
+// synonyms. Answers to words we don't know are wrong.
+func gradeAnswer(word string, answer string) bool {
+	completeWord, exists := cachedWords[word]
+	if !exists {
+		return false
+	}
+	rules := answerRules.With(completeWord.Grading)
+	return rules.Matches(answer, completeWord.Correct, completeWord.Synonyms)
+}
+
+// answeredAt returns when the result was answered, now unless the client
+// said otherwise. It reports false if the client's time is implausible.
+func answeredAt(result WordResults, now time.Time) (time.Time, bool) {
+	if result.AnsweredAt == "" {
+		return now, true
+	}
+	at, err := time.Parse(time.RFC3339, result.AnsweredAt)
+	if err != nil || at.After(now.Add(answerClockSkew)) || now.Sub(at) > maxAnswerAge {
+		return time.Time{}, false
+	}
+	return at, true
+}
+
+// gradeStatement checks the answer ("true" or "false") to whether the word
+// means statement. The statement is true if it is an accepted answer.
+func gradeStatement(word string, statement string, answer string) bool {
+	claim, err := strconv.ParseBool(answer)
 	if err != nil {
-		log.Printf("Error updating WordStatistics: %v", err)
-		return err
+		return false
 	}
-	return nil
+	if _, exists := cachedWords[word]; !exists {
+		return false
+	}
+	return claim == gradeAnswer(word, statement)
+}
+
+// Results for words we don't know award no XP
+func scoreResult(result WordResults, now time.Time) int {
+	word, exists := cachedWords[result.Word]
+	if !exists {
+		return 0
+	}
+	return scoringTable.Points(scoring.Answer{
+		Difficulty:      word.Difficulty,
+		IsCorrect:       result.IsCorrect,
+		ResponseTimeMs:  result.ResponseTimeMs,
+		HintUsed:        result.HintUsed,
+		Retries:         result.Retries,
+		EventMultiplier: eventMultiplier(word, now),
+	})
 }
 
 func main() {
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"hpmaster/internal/question"
+)
+
+const (
+	defaultNumChoices = 4
+	// statementTokenTTL is how long the results of a true/false session
+	// may be uploaded after it was fetched.
+	statementTokenTTL = 24 * time.Hour
+)
+
+// questionOptions reads the format, numChoices and shuffle query parameters
+// of GET /words. It returns nil if none is set, in which case the words are
+// sent as they are stored. Otherwise a message for an invalid parameter may
+// be returned instead.
+func questionOptions(params map[string]string) (*question.Options, string) {
+	format, numChoices, shuffle := params["format"], params["numChoices"], params["shuffle"]
+	if format == "" && numChoices == "" && shuffle == "" {
+		return nil, ""
+	}
+
+	opts := &question.Options{
+		Format:     question.MultipleChoice,
+		NumChoices: defaultNumChoices,
+		Shuffle:    true,
+		Rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	if format != "" {
+		opts.Format = format
+	}
+	if !question.IsFormat(opts.Format) {
+		return nil, "Invalid format parameter"
+	}
+	if opts.Format == question.TrueFalse {
+		if statementSigner == nil {
+			return nil, "Format truefalse is not available"
+		}
+		opts.Signer = statementSigner
+		opts.Now = time.Now()
+	}
+	if numChoices != "" {
+		var err error
+		opts.NumChoices, err = strconv.Atoi(numChoices)
+		if err != nil || opts.NumChoices < question.MinChoices || opts.NumChoices > question.MaxChoices {
+			return nil, "Invalid numChoices parameter"
+		}
+	}
+	if shuffle != "" {
+		var err error
+		opts.Shuffle, err = strconv.ParseBool(shuffle)
+		if err != nil {
+			return nil, "Invalid shuffle parameter"
+		}
+	}
+	return opts, ""
+}
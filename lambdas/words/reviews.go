@@ -0,0 +1,90 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+	"hpmaster/internal/streak"
+)
+
+type Review struct {
+	Word         string  `json:"word"`
+	NextReviewAt string  `json:"nextReviewAt,omitempty"`
+	IntervalDays int     `json:"intervalDays"`
+	SuccessRatio float32 `json:"successRatio"`
+}
+
+type ReviewQueue struct {
+	// Overdue counts reviews that were due before today (UTC), including
+	// words practiced before reviews were scheduled.
+	Overdue  int      `json:"overdue"`
+	Today    int      `json:"today"`
+	Upcoming int      `json:"upcoming"`
+	Reviews  []Review `json:"reviews"`
+}
+
+// handleGetReviews lists the caller's words ordered by their next review.
+func handleGetReviews(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	limit := 50
+	if limitStr := event.QueryStringParameters["limit"]; limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit < 0 {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid limit parameter"}, nil
+		}
+	}
+
+	stats, err := userStore.ListWordStatistics(*userId)
+	if err != nil {
+		log.Printf("Error retrieving word statistics: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	return api.JSON(buildReviewQueue(stats, time.Now(), limit))
+}
+
+func buildReviewQueue(stats []WordStatistics, now time.Time, limit int) ReviewQueue {
+	today := streak.Day(now).Format(time.RFC3339)
+	tomorrow := streak.Day(now).AddDate(0, 0, 1).Format(time.RFC3339)
+
+	queue := ReviewQueue{Reviews: make([]Review, 0, len(stats))}
+	for _, stat := range stats {
+		if _, exists := cachedWords[stat.Word]; !exists {
+			continue
+		}
+		// Words never scheduled count as overdue
+		switch {
+		case store.CompareTimestamps(stat.NextReviewAt, today) < 0:
+			queue.Overdue++
+		case store.CompareTimestamps(stat.NextReviewAt, tomorrow) < 0:
+			queue.Today++
+		default:
+			queue.Upcoming++
+		}
+		queue.Reviews = append(queue.Reviews, Review{
+			Word:         stat.Word,
+			NextReviewAt: stat.NextReviewAt,
+			IntervalDays: stat.IntervalDays,
+			SuccessRatio: stat.SuccessRatio,
+		})
+	}
+
+	sort.SliceStable(queue.Reviews, func(i, j int) bool {
+		return store.CompareTimestamps(queue.Reviews[i].NextReviewAt, queue.Reviews[j].NextReviewAt) < 0
+	})
+	if len(queue.Reviews) > limit {
+		queue.Reviews = queue.Reviews[:limit]
+	}
+	return queue
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"hpmaster/internal/selection"
+	"hpmaster/internal/timing"
+)
+
+// defaultStrategy selects words the original way: the weakest words from the
+// successRatio index topped up with random ones, see getWords.
+const defaultStrategy = "default"
+
+// selectWords picks the words of a session with the named strategy of
+// package selection.
+func selectWords(userID string, limit int, selector selection.Selector, params map[string]string, budget *timing.Budget) ([]Word, error) {
+	stop := budget.Stage("packFilter")
+	include, err := practiceFilter(userID)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	stop = budget.Stage("statsQuery")
+	stats, err := userStore.ListWordStatistics(userID)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+	defer budget.Stage("selection")()
+
+	now := time.Now()
+	req := &selection.Request{
+		UserId:        userID,
+		Limit:         limit,
+		Stats:         make(map[string]WordStatistics, len(stats)),
+		NewWordBudget: remainingNewWords(stats, now),
+		Now:           now,
+		Params:        params,
+	}
+	for _, stat := range stats {
+		req.Stats[stat.Word] = stat
+	}
+	for _, word := range cachedWords {
+		if include(word) {
+			req.Candidates = append(req.Candidates, word)
+		}
+	}
+	return selection.Select(selector, req), nil
+}
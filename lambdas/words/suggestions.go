@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/dedupe"
+	"hpmaster/internal/store"
+)
+
+// handleSuggestWord stores a word proposed by the user for an operator to
+// approve, see the suggestions commands of cmd/admin. Nothing is served from
+// it until then. A word that clashes with a known one is refused with the
+// conflict report.
+func handleSuggestWord(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	var word Word
+	if err := json.Unmarshal([]byte(event.Body), &word); err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid request body"}, nil
+	}
+	if word.Word == "" || word.Correct == "" || len(word.Incorrect) == 0 {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "word, correct and incorrect are required"}, nil
+	}
+	// Grading overrides are for operators to set
+	word.Grading = nil
+
+	// Read the table rather than cachedWords, which misses words imported
+	// since the container started
+	stored, err := userStore.ListWords()
+	if err != nil {
+		log.Printf("Error listing words: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	existing := make([]string, 0, len(stored))
+	for _, known := range stored {
+		existing = append(existing, known.Word)
+	}
+	if conflicts := dedupe.Check(existing, []string{word.Word}, store.WordsLanguage); len(conflicts) > 0 {
+		body, err := json.Marshal(conflicts)
+		if err != nil {
+			log.Printf("Error marshalling conflicts: %v", err)
+			return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+		}
+		return events.APIGatewayProxyResponse{StatusCode: 409, Body: string(body)}, nil
+	}
+
+	suggestion, err := userStore.PutSuggestion(*userId, word, time.Now())
+	if err != nil {
+		log.Printf("Error storing suggestion: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(suggestion)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/ratelimit"
+)
+
+// countUsage counts n uses of the counter by the user and reports false with
+// a 429 response once the user is over the limit. Counting is best effort:
+// if the Usage table can't be updated the request is let through.
+func countUsage(userId string, counter string, n int64) (events.APIGatewayProxyResponse, bool) {
+	now := time.Now()
+	err := limiter.Add(userId, counter, n, now)
+	if err == ratelimit.ErrLimitExceeded {
+		retryAfter := int(ratelimit.PeriodEnd(now).Sub(now).Seconds()) + 1
+		return events.APIGatewayProxyResponse{
+			StatusCode: 429,
+			Headers:    map[string]string{"Retry-After": strconv.Itoa(retryAfter)},
+			Body:       "Usage limit exceeded",
+		}, false
+	}
+	if err != nil {
+		log.Printf("Error counting usage: %v", err)
+	}
+	return events.APIGatewayProxyResponse{}, true
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"hpmaster/internal/api"
+	"hpmaster/internal/store"
+)
+
+const maxWordStatsLimit = 200
+
+type WordStat struct {
+	Word            string  `json:"word"`
+	Category        string  `json:"category,omitempty"`
+	Attempts        int     `json:"attempts"`
+	Success         int     `json:"success"`
+	SuccessRatio    float32 `json:"successRatio"`
+	LastPracticedAt string  `json:"lastPracticedAt,omitempty"`
+	NextReviewAt    string  `json:"nextReviewAt,omitempty"`
+}
+
+type WordStatsPage struct {
+	Items []WordStat `json:"items"`
+	// NextCursor is passed as the cursor parameter to get the next page. It
+	// is omitted on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// wordStatsOrders are the supported values of the sort parameter. Ties are
+// broken by word so every order is total, which the cursor relies on.
+var wordStatsOrders = map[string]func(a, b WordStat) bool{
+	// Lowest success ratio first, like the words picked for practice
+	"worst": func(a, b WordStat) bool {
+		if a.SuccessRatio != b.SuccessRatio {
+			return a.SuccessRatio < b.SuccessRatio
+		}
+		return a.Word < b.Word
+	},
+	"practiced": func(a, b WordStat) bool {
+		if a.Attempts != b.Attempts {
+			return a.Attempts > b.Attempts
+		}
+		return a.Word < b.Word
+	},
+	"recent": func(a, b WordStat) bool {
+		if c := store.CompareTimestamps(a.LastPracticedAt, b.LastPracticedAt); c != 0 {
+			return c > 0
+		}
+		return a.Word < b.Word
+	},
+}
+
+// handleGetWordStats pages through the caller's word statistics.
+//
+// Query parameters: sort (worst, practiced or recent, default worst),
+// category, limit (default 50) and cursor.
+func handleGetWordStats(event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	userId, errResponse := authenticate(event)
+	if userId == nil {
+		return errResponse, nil
+	}
+
+	params := event.QueryStringParameters
+	order := params["sort"]
+	if order == "" {
+		order = "worst"
+	}
+	less, exists := wordStatsOrders[order]
+	if !exists {
+		return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid sort parameter"}, nil
+	}
+
+	limit := 50
+	if limitStr := params["limit"]; limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxWordStatsLimit {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid limit parameter"}, nil
+		}
+	}
+
+	var after *WordStat
+	if cursor := params["cursor"]; cursor != "" {
+		var err error
+		after, err = decodeWordStatsCursor(cursor)
+		if err != nil {
+			return events.APIGatewayProxyResponse{StatusCode: 400, Body: "Invalid cursor parameter"}, nil
+		}
+	}
+
+	stats, err := userStore.ListWordStatistics(*userId)
+	if err != nil {
+		log.Printf("Error retrieving word statistics: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+
+	page, err := buildWordStatsPage(stats, params["category"], less, after, limit)
+	if err != nil {
+		log.Printf("Error encoding cursor: %v", err)
+		return events.APIGatewayProxyResponse{StatusCode: 500, Body: "Internal server error"}, nil
+	}
+	return api.JSON(page)
+}
+
+// buildWordStatsPage returns the limit entries that follow after in the given
+// order. The cursor holds the last entry of the page rather than an offset,
+// so words practiced between two requests don't shift the pages.
+func buildWordStatsPage(stats []WordStatistics, category string, less func(a, b WordStat) bool, after *WordStat, limit int) (WordStatsPage, error) {
+	page := WordStatsPage{Items: make([]WordStat, 0, limit)}
+	var entries []WordStat
+	for _, stat := range stats {
+		word, exists := cachedWords[stat.Word]
+		if !exists || (category != "" && word.Category != category) {
+			continue
+		}
+		entry := WordStat{
+			Word:            stat.Word,
+			Category:        word.Category,
+			Attempts:        stat.Attempts,
+			Success:         stat.Success,
+			SuccessRatio:    stat.SuccessRatio,
+			LastPracticedAt: stat.LastPracticedAt,
+			NextReviewAt:    stat.NextReviewAt,
+		}
+		if after != nil && !less(*after, entry) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return less(entries[i], entries[j])
+	})
+	if len(entries) <= limit {
+		page.Items = append(page.Items, entries...)
+		return page, nil
+	}
+
+	page.Items = append(page.Items, entries[:limit]...)
+	cursor, err := encodeWordStatsCursor(entries[limit-1])
+	if err != nil {
+		return page, err
+	}
+	page.NextCursor = cursor
+	return page, nil
+}
+
+func encodeWordStatsCursor(last WordStat) (string, error) {
+	data, err := json.Marshal(last)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeWordStatsCursor(cursor string) (*WordStat, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	var last WordStat
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, err
+	}
+	return &last, nil
+}